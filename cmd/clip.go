@@ -7,6 +7,7 @@ import (
 	"github.com/atotto/clipboard"
 	"github.com/fatih/color"
 	"github.com/knqyf263/pet/config"
+	"github.com/knqyf263/pet/snippet"
 	"github.com/spf13/cobra"
 )
 
@@ -31,10 +32,14 @@ func clip(cmd *cobra.Command, args []string) (err error) {
 		return err
 	}
 	command := strings.Join(commands, flag.Delimiter)
+	resolved, err := snippet.ResolveSecrets(command)
+	if err != nil {
+		return err
+	}
 	if flag.Command && command != "" {
 		fmt.Printf("%s: %s\n", color.YellowString("Command"), command)
 	}
-	return clipboard.WriteAll(command)
+	return clipboard.WriteAll(resolved)
 }
 
 func init() {
@@ -47,4 +52,10 @@ func init() {
 		`Use delim as the command delimiter character`)
 	clipCmd.Flags().StringVarP(&config.Flag.FilterTag, "tag", "t", "",
 		`Filter tag`)
+	clipCmd.Flags().StringVarP(&config.Flag.SnippetID, "id", "", "",
+		`Select the snippet with this ID instead of prompting`)
+	clipCmd.Flags().StringArrayVarP(&config.Flag.Params, "param", "p", nil,
+		`Fill in a parameter without the dialog (key=value, repeatable)`)
+	clipCmd.Flags().BoolVarP(&config.Flag.NoPrompt, "no-prompt", "", false,
+		`Use default values for parameters not given with --param instead of the dialog`)
 }
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/knqyf263/pet/config"
+	"github.com/knqyf263/pet/path"
+	"github.com/knqyf263/pet/snippet"
+	petSync "github.com/knqyf263/pet/sync"
+	"github.com/spf13/cobra"
+)
+
+// convertCmd represents the convert command
+var convertCmd = &cobra.Command{
+	Use:   "convert <snippet file> <toml|yaml|json>",
+	Short: "Convert a snippet file to another format",
+	Long: `Rewrite a snippet file of a snippet directory in another format, next to it with the
+extension of the format. The original file is kept as its .bak file.`,
+	Args: cobra.ExactArgs(2),
+	RunE: convert,
+}
+
+func convert(cmd *cobra.Command, args []string) (err error) {
+	return _convert(os.Stdout, args[0], args[1])
+}
+
+func _convert(out io.Writer, file string, format string) error {
+	filePath, err := path.NewAbsolutePath(file)
+	if err != nil {
+		return err
+	}
+	mainFile, err := path.NewAbsolutePath(config.Conf.General.SnippetFile)
+	if err != nil {
+		return err
+	}
+	if filePath.Get() == mainFile.Get() {
+		return errors.New("the main snippet file is set in the config file, convert files of snippet directories instead")
+	}
+
+	converted, err := snippet.ConvertFile(filePath.Get(), format)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Converted %s to %s\n", filePath.Get(), converted)
+
+	return petSync.AfterChange(configFile)
+}
+
+func init() {
+	RootCmd.AddCommand(convertCmd)
+}
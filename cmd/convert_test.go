@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/knqyf263/pet/config"
+	"github.com/knqyf263/pet/snippet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvert(t *testing.T) {
+	tempDir, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	dir := filepath.Join(tempDir, "snippets")
+	require.NoError(t, os.MkdirAll(dir, 0700))
+	config.Conf.General.SnippetDirs = []string{dir}
+	file := filepath.Join(dir, "docker.toml")
+	saveSnippetsToFile(t, file, snippet.Snippets{
+		Snippets: []snippet.SnippetInfo{{ID: "d", Description: "containers", Command: "docker ps"}},
+	})
+
+	var stdout bytes.Buffer
+	require.NoError(t, _convert(&stdout, file, "json"))
+	assert.Equal(t, "Converted "+file+" to "+filepath.Join(dir, "docker.json")+"\n", stdout.String())
+
+	var snippets snippet.Snippets
+	require.NoError(t, snippets.Load(true))
+	s, ok := snippets.FindByID("d")
+	require.True(t, ok)
+	assert.Equal(t, filepath.Join(dir, "docker.json"), s.Filename)
+	assert.Len(t, snippets.Snippets, 3)
+
+	err := _convert(&stdout, config.Conf.General.SnippetFile, "yaml")
+	assert.EqualError(t, err, "the main snippet file is set in the config file, convert files of snippet directories instead")
+}
@@ -1,12 +1,18 @@
 package cmd
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"os"
+	"strings"
 
+	"github.com/fatih/color"
 	"github.com/knqyf263/pet/config"
 	"github.com/knqyf263/pet/path"
+	"github.com/knqyf263/pet/snippet"
 	petSync "github.com/knqyf263/pet/sync"
+	"github.com/pelletier/go-toml"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"gopkg.in/alessio/shellescape.v1"
@@ -33,9 +39,13 @@ func edit(cmd *cobra.Command, args []string) (err error) {
 		options = append(options, fmt.Sprintf("--query %s", shellescape.Quote(flag.Query)))
 	}
 
+	if flag.EditSnippet {
+		return editSnippet(os.Stdin, os.Stdout, options)
+	}
+
 	// If we have multiple snippet directories, we need to find the right
 	// snippet file to edit - so we need to prompt the user to select a snippet first
-	if len(config.Conf.General.SnippetDirs) > 0 {
+	if len(config.Conf.General.SnippetDirs) > 0 || flag.SnippetID != "" {
 		snippetFilePath, err = selectFile(options, flag.FilterTag)
 		if err != nil {
 			return err
@@ -45,9 +55,19 @@ func edit(cmd *cobra.Command, args []string) (err error) {
 		return errors.New("No snippet file selected")
 	}
 
+	var before snippet.Snippets
+	if err := before.Load(true); err != nil {
+		return err
+	}
+
+	// The file may have been changed outside of pet since its last version
+	if err := recordFileVersion(snippetFilePath, snippet.ReasonOriginal); err != nil {
+		return err
+	}
+
 	// only sync if content has changed
 	contentBefore := fileContent(snippetFilePath)
-	err = editFile(editor, snippetFilePath, 0)
+	err = editSnippetFile(editor, snippetFilePath, 0)
 	if err != nil {
 		return err
 	}
@@ -56,12 +76,214 @@ func edit(cmd *cobra.Command, args []string) (err error) {
 		return nil
 	}
 
+	if err := stampEditedSnippets(before); err != nil {
+		return err
+	}
+	if err := recordFileVersion(snippetFilePath, snippet.ReasonEdit); err != nil {
+		return err
+	}
+
 	// sync snippet file
-	if config.Conf.Gist.AutoSync {
-		return petSync.AutoSync(snippetFilePath)
+	return petSync.AfterChange(configFile)
+}
+
+// editSnippet opens a single selected snippet in the editor
+// and writes it back to the snippet file it came from.
+func editSnippet(in io.ReadCloser, out io.Writer, options []string) error {
+	selected, err := selectSnippets(options, config.Flag.FilterTag)
+	if err != nil {
+		return err
+	}
+	if len(selected) == 0 {
+		return errors.New("No snippet selected")
+	}
+	original := selected[0]
+	if err := snippet.CheckWritable(original.Filename); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp("", "pet-*.toml")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) // clean up temp file
+
+	err = toml.NewEncoder(f).Encode(snippet.Snippets{Snippets: []snippet.SnippetInfo{original}})
+	f.Close()
+	if err != nil {
+		return errors.Wrap(err, "Failed to write snippet to temporary file")
+	}
+
+	tempFilePath, err := path.NewAbsolutePath(f.Name())
+	if err != nil {
+		return err
+	}
+
+	var edited snippet.SnippetInfo
+	for {
+		if err := editFile(config.Conf.General.Editor, tempFilePath, 0); err != nil {
+			return err
+		}
+
+		edited, err = parseEditedSnippet([]byte(fileContent(tempFilePath)))
+		if err == nil {
+			break
+		}
+
+		// Let the user fix the snippet instead of losing the changes
+		fmt.Fprintf(out, "%s %v\n", color.HiRedString("Invalid snippet:"), err)
+		answer, err := scan(color.HiYellowString("Re-open the editor? [Y/n] "), out, in, true)
+		if err != nil {
+			return err
+		}
+		if strings.EqualFold(answer, "n") || strings.EqualFold(answer, "no") {
+			return CanceledError()
+		}
+	}
+
+	// The snippet keeps its identity and file no matter what was edited
+	edited.ID = original.ID
+	edited.Filename = original.Filename
+	if original.Equal(edited) {
+		return nil
+	}
+	edited.UpdatedAt = snippet.Now()
+
+	if err := saveEditedSnippet(edited); err != nil {
+		return err
+	}
+
+	// sync snippet file
+	return petSync.AfterChange(configFile)
+}
+
+// saveEditedSnippet replaces a snippet with its edited version. The snippets are loaded
+// again under the lock, so that snippets saved by another pet meanwhile are kept.
+func saveEditedSnippet(edited snippet.SnippetInfo) error {
+	unlock, err := snippet.Lock()
+	if err != nil {
+		return err
 	}
+	defer unlock()
 
-	return nil
+	var snippets snippet.Snippets
+	if err := snippets.Load(true); err != nil {
+		return err
+	}
+	if !snippets.Update(edited) {
+		return fmt.Errorf("snippet not found: %s", edited.ID)
+	}
+	return snippets.Save()
+}
+
+// stampEditedSnippets sets the creation and modification time of the snippets
+// which were added or changed compared to the snippets loaded before editing
+func stampEditedSnippets(before snippet.Snippets) error {
+	unlock, err := snippet.Lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	var after snippet.Snippets
+	if err := after.Load(true); err != nil {
+		return err
+	}
+
+	changed := false
+	now := snippet.Now()
+	for i, s := range after.Snippets {
+		old, ok := before.FindByID(s.ID)
+		switch {
+		case snippet.IsReadOnly(s.Filename):
+			continue
+		case !ok:
+			if s.CreatedAt.IsZero() {
+				after.Snippets[i].CreatedAt = now
+			}
+			after.Snippets[i].UpdatedAt = now
+		case !old.Equal(s):
+			after.Snippets[i].UpdatedAt = now
+		default:
+			continue
+		}
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+	return after.Save()
+}
+
+// parseEditedSnippet parses and validates a TOML document holding exactly one snippet
+func parseEditedSnippet(data []byte) (snippet.SnippetInfo, error) {
+	var snippets snippet.Snippets
+	if err := toml.Unmarshal(data, &snippets); err != nil {
+		return snippet.SnippetInfo{}, err
+	}
+
+	if len(snippets.Snippets) != 1 {
+		return snippet.SnippetInfo{}, fmt.Errorf("expected exactly one snippet, got %d", len(snippets.Snippets))
+	}
+
+	s := snippets.Snippets[0]
+	if strings.TrimSpace(s.Command) == "" {
+		return snippet.SnippetInfo{}, errors.New("command must not be empty")
+	}
+	return s, nil
+}
+
+// editSnippetFile opens a snippet file in the editor. An encrypted file, or one to be
+// encrypted, is edited as a decrypted temporary copy and written back if it changed.
+func editSnippetFile(editor string, filePath path.AbsolutePath, startingLine int) error {
+	data, err := os.ReadFile(filePath.Get())
+	if err != nil || !snippet.IsEncrypted(data) && !config.Conf.Encryption.Files {
+		return editFile(editor, filePath, startingLine)
+	}
+
+	plain, err := snippet.Decrypt(data)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp("", "pet-*.toml")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) // clean up temp file
+
+	_, err = f.Write(plain)
+	f.Close()
+	if err != nil {
+		return errors.Wrap(err, "Failed to write snippets to temporary file")
+	}
+
+	tempFilePath, err := path.NewAbsolutePath(f.Name())
+	if err != nil {
+		return err
+	}
+	if err := editFile(editor, tempFilePath, startingLine); err != nil {
+		return err
+	}
+
+	edited, err := os.ReadFile(f.Name())
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(edited, plain) {
+		return nil
+	}
+	return snippet.WriteFile(filePath.Get(), edited, 0666)
+}
+
+// recordFileVersion records the current content of a snippet file in the journal
+func recordFileVersion(filePath path.AbsolutePath, reason string) error {
+	data, err := snippet.ReadFile(filePath.Get())
+	if err != nil {
+		return err
+	}
+	return snippet.RecordVersion(filePath.Get(), data, reason)
 }
 
 func fileContent(filePath path.AbsolutePath) string {
@@ -75,4 +297,8 @@ func init() {
 		`Initial value for query`)
 	editCmd.Flags().StringVarP(&config.Flag.FilterTag, "tag", "t", "",
 		`Filter tag`)
+	editCmd.Flags().BoolVarP(&config.Flag.EditSnippet, "snippet", "s", false,
+		`Edit a single selected snippet instead of the whole file`)
+	editCmd.Flags().StringVarP(&config.Flag.SnippetID, "id", "", "",
+		`Select the snippet with this ID instead of prompting`)
 }
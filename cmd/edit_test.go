@@ -0,0 +1,143 @@
+//go:build !windows
+
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/knqyf263/pet/config"
+	"github.com/knqyf263/pet/snippet"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseEditedSnippet(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "success",
+			data: "[[Snippets]]\n  Description = \"test\"\n  command = \"echo test\"\n",
+			want: "echo test",
+		},
+		{
+			name:    "error - invalid toml",
+			data:    "[[Snippets]\n",
+			wantErr: true,
+		},
+		{
+			name:    "error - no snippet",
+			data:    "",
+			wantErr: true,
+		},
+		{
+			name:    "error - multiple snippets",
+			data:    "[[Snippets]]\n  command = \"echo 1\"\n[[Snippets]]\n  command = \"echo 2\"\n",
+			wantErr: true,
+		},
+		{
+			name:    "error - empty command",
+			data:    "[[Snippets]]\n  Description = \"test\"\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseEditedSnippet([]byte(tt.data))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got.Command)
+		})
+	}
+}
+
+func TestEditSnippet(t *testing.T) {
+	tempDir, cleanup := setupTestConfig(t)
+	defer cleanup()
+	defer func() { config.Flag.SnippetID = "" }()
+
+	var snippets snippet.Snippets
+	assert.NoError(t, snippets.Load(false))
+	config.Flag.SnippetID = snippets.Snippets[0].ID
+
+	// The "editor" overwrites the temporary snippet file
+	config.Conf.General.Editor = `sh -c 'printf "[[Snippets]]\n  Description = \"edited\"\n  command = \"echo edited\"\n" > "$2"' _`
+
+	var stdout bytes.Buffer
+	stdin := &MockReadCloser{strings.NewReader("")}
+
+	err := editSnippet(stdin, &stdout, nil)
+	assert.NoError(t, err)
+
+	var updated snippet.Snippets
+	loadSnippetsFromFile(t, filepath.Join(tempDir, "snippet.toml"), &updated)
+	assert.Len(t, updated.Snippets, 2)
+	assert.Equal(t, snippets.Snippets[0].ID, updated.Snippets[0].ID)
+	assert.Equal(t, "edited", updated.Snippets[0].Description)
+	assert.Equal(t, "echo edited", updated.Snippets[0].Command)
+	assert.Equal(t, "echo something else", updated.Snippets[1].Command)
+}
+
+func TestEditSnippetUnchanged(t *testing.T) {
+	tempDir, cleanup := setupTestConfig(t)
+	defer cleanup()
+	defer func() { config.Flag.SnippetID = "" }()
+
+	// A snippet without tags, which the temporary file gives an empty list of tags
+	snippetFile := filepath.Join(tempDir, "snippet.toml")
+	assert.NoError(t, os.WriteFile(snippetFile, []byte(`[[Snippets]]
+  id = "01HZZZZZZZZZZZZZZZZZZZZZZZ"
+  Description = "list"
+  command = "ls"
+  created_at = 2024-01-02T03:04:05+09:00
+  updated_at = 2024-01-02T03:04:05+09:00
+`), 0644))
+	config.Flag.SnippetID = "01HZZZZZZZZZZZZZZZZZZZZZZZ"
+	before, err := os.ReadFile(snippetFile)
+	assert.NoError(t, err)
+
+	// The editor is closed without changing anything
+	config.Conf.General.Editor = "true"
+	stdin := &MockReadCloser{strings.NewReader("")}
+	assert.NoError(t, editSnippet(stdin, &bytes.Buffer{}, nil))
+
+	after, err := os.ReadFile(snippetFile)
+	assert.NoError(t, err)
+	assert.Equal(t, string(before), string(after))
+	journal, err := snippet.LoadJournal()
+	assert.NoError(t, err)
+	for _, v := range journal.Versions {
+		assert.NotEqual(t, snippet.ReasonEdit, v.Reason)
+	}
+}
+
+func TestEditFileCanBeUndone(t *testing.T) {
+	tempDir, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	// The "editor" replaces both snippets with another one
+	config.Conf.General.Editor = `sh -c 'printf "[[Snippets]]\n  Description = \"kept\"\n  command = \"echo kept\"\n" > "$2"' _`
+	assert.NoError(t, edit(nil, nil))
+
+	var edited snippet.Snippets
+	loadSnippetsFromFile(t, filepath.Join(tempDir, "snippet.toml"), &edited)
+	assert.Len(t, edited.Snippets, 1)
+
+	var stdout bytes.Buffer
+	assert.NoError(t, _undo(&stdout))
+
+	var restored snippet.Snippets
+	loadSnippetsFromFile(t, filepath.Join(tempDir, "snippet.toml"), &restored)
+	assert.Len(t, restored.Snippets, 2)
+	assert.Equal(t, "echo main", restored.Snippets[0].Command)
+}
@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/knqyf263/pet/config"
+	"github.com/knqyf263/pet/snippet"
 	"github.com/spf13/cobra"
 	"gopkg.in/alessio/shellescape.v1"
 )
@@ -32,13 +33,17 @@ func _execute(in io.ReadCloser, out io.Writer) (err error) {
 		return err
 	}
 	command := strings.Join(commands, "; ")
+	resolved, err := snippet.ResolveSecrets(command)
+	if err != nil {
+		return err
+	}
 
-	// Show final command before executing it
+	// Show final command before executing it, secrets stay masked by their references
 	if !flag.Silent {
 		fmt.Fprintf(out, "> %s\n", command)
 	}
 
-	return run(command, in, out)
+	return run(resolved, in, out)
 }
 
 func execute(cmd *cobra.Command, args []string) error {
@@ -55,4 +60,10 @@ func init() {
 		`Filter tag`)
 	execCmd.Flags().BoolVarP(&config.Flag.Silent, "silent", "s", false,
 		`Suppress the command output`)
+	execCmd.Flags().StringVarP(&config.Flag.SnippetID, "id", "", "",
+		`Select the snippet with this ID instead of prompting`)
+	execCmd.Flags().StringArrayVarP(&config.Flag.Params, "param", "p", nil,
+		`Fill in a parameter without the dialog (key=value, repeatable)`)
+	execCmd.Flags().BoolVarP(&config.Flag.NoPrompt, "no-prompt", "", false,
+		`Use default values for parameters not given with --param instead of the dialog`)
 }
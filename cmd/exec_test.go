@@ -32,6 +32,10 @@ func setupTestConfig(t *testing.T) (string, func()) {
 
 	// Mock configuration
 	config.Conf.General.SnippetFile = tempSnippetFile
+	config.Conf.General.SnippetDirs = nil
+	config.Conf.General.StateFile = filepath.Join(tempDir, "state.toml")
+	config.Conf.General.ParamHistoryFile = filepath.Join(tempDir, "history.toml")
+	config.Conf.General.JournalDir = filepath.Join(tempDir, "journal")
 
 	// Set SelectCmd to a valid command with piping
 	config.Conf.General.SelectCmd = "fzf"
@@ -79,3 +83,163 @@ func TestExecute_SilentFlag(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, stdout.String(), "")
 }
+
+func TestExecute_SnippetID(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+	defer func() { config.Flag.SnippetID = "" }()
+
+	var snippets snippet.Snippets
+	err := snippets.Load(false)
+	assert.NoError(t, err)
+
+	var stdout bytes.Buffer
+	stdin := &MockReadCloser{strings.NewReader("\n")}
+
+	config.Flag.Silent = false
+	config.Flag.SnippetID = snippets.Snippets[1].ID
+
+	err = _execute(stdin, &stdout)
+	assert.NoError(t, err)
+	assert.Equal(t, "> echo something else\nsomething else\n", stdout.String())
+}
+
+func TestExecute_UnknownSnippetID(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+	defer func() { config.Flag.SnippetID = "" }()
+
+	var stdout bytes.Buffer
+	stdin := &MockReadCloser{strings.NewReader("\n")}
+
+	config.Flag.SnippetID = "unknown"
+
+	err := _execute(stdin, &stdout)
+	assert.EqualError(t, err, "snippet not found: unknown")
+}
+
+func TestExecute_MarksSnippetAsUsed(t *testing.T) {
+	tempDir, cleanup := setupTestConfig(t)
+	defer cleanup()
+	defer func() { config.Flag.SnippetID = "" }()
+
+	var snippets snippet.Snippets
+	err := snippets.Load(false)
+	assert.NoError(t, err)
+
+	snippetFile := filepath.Join(tempDir, "snippet.toml")
+	before, err := os.ReadFile(snippetFile)
+	assert.NoError(t, err)
+
+	var stdout bytes.Buffer
+	stdin := &MockReadCloser{strings.NewReader("\n")}
+
+	config.Flag.SnippetID = snippets.Snippets[1].ID
+
+	err = _execute(stdin, &stdout)
+	assert.NoError(t, err)
+
+	// The use is recorded in the state file, the snippet file is left alone
+	stats, err := snippet.LoadUsage()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, stats.Usage[snippets.Snippets[1].ID].Count)
+	assert.NotContains(t, stats.Usage, snippets.Snippets[0].ID)
+
+	after, err := os.ReadFile(snippetFile)
+	assert.NoError(t, err)
+	assert.Equal(t, string(before), string(after))
+}
+
+func TestExecute_ParamFlags(t *testing.T) {
+	tempDir, cleanup := setupTestConfig(t)
+	defer cleanup()
+	defer func() {
+		config.Flag.SnippetID = ""
+		config.Flag.Params = nil
+		config.Flag.NoPrompt = false
+	}()
+
+	saveSnippetsToFile(t, filepath.Join(tempDir, "snippet.toml"), snippet.Snippets{
+		Snippets: []snippet.SnippetInfo{
+			{ID: "params", Description: "params", Command: "echo <greeting=hello> <name>"},
+		},
+	})
+	config.Flag.SnippetID = "params"
+	config.Flag.Silent = true
+
+	t.Run("success - all params given", func(t *testing.T) {
+		var stdout bytes.Buffer
+		config.Flag.Params = []string{"greeting=hi", "name=pet"}
+
+		err := _execute(&MockReadCloser{strings.NewReader("")}, &stdout)
+		assert.NoError(t, err)
+		assert.Equal(t, "hi pet\n", stdout.String())
+	})
+
+	t.Run("success - defaults with no-prompt", func(t *testing.T) {
+		var stdout bytes.Buffer
+		config.Flag.Params = []string{"name=pet"}
+		config.Flag.NoPrompt = true
+
+		err := _execute(&MockReadCloser{strings.NewReader("")}, &stdout)
+		assert.NoError(t, err)
+		assert.Equal(t, "hello pet\n", stdout.String())
+	})
+
+	t.Run("success - given values are remembered", func(t *testing.T) {
+		history, err := snippet.LoadParamHistory()
+		assert.NoError(t, err)
+		assert.Equal(t, map[string][]string{"greeting": {"hi"}, "name": {"pet"}}, history.Values("params"))
+	})
+
+	t.Run("error - missing value with no-prompt", func(t *testing.T) {
+		var stdout bytes.Buffer
+		config.Flag.Params = nil
+		config.Flag.NoPrompt = true
+
+		err := _execute(&MockReadCloser{strings.NewReader("")}, &stdout)
+		assert.EqualError(t, err, "missing value for parameter(s): name")
+	})
+
+	t.Run("error - invalid param flag", func(t *testing.T) {
+		var stdout bytes.Buffer
+		config.Flag.Params = []string{"name"}
+
+		err := _execute(&MockReadCloser{strings.NewReader("")}, &stdout)
+		assert.EqualError(t, err, `invalid parameter "name", expected key=value`)
+	})
+}
+
+func TestExecute_MasksSecrets(t *testing.T) {
+	tempDir, cleanup := setupTestConfig(t)
+	defer cleanup()
+	defer func() {
+		config.Flag.SnippetID = ""
+		config.Conf.Secret = config.SecretConfig{}
+	}()
+
+	saveSnippetsToFile(t, filepath.Join(tempDir, "snippet.toml"), snippet.Snippets{
+		Snippets: []snippet.SnippetInfo{
+			{ID: "secret", Description: "secret", Command: "echo token={{secret:pet_test_token}}"},
+		},
+	})
+	config.Conf.Secret.File = filepath.Join(tempDir, "secrets.toml")
+	config.Flag.SnippetID = "secret"
+	config.Flag.Silent = false
+
+	var stdout bytes.Buffer
+	err := _execute(&MockReadCloser{strings.NewReader("")}, &stdout)
+	assert.EqualError(t, err, `secret not found: pet_test_token.
+Export $pet_test_token, write it in the secrets file or set helper in [Secret] in config file (pet configure).`)
+	assert.Empty(t, stdout.String())
+
+	t.Setenv("pet_test_token", "s3cr3t")
+	err = _execute(&MockReadCloser{strings.NewReader("")}, &stdout)
+	assert.NoError(t, err)
+	assert.Equal(t, "> echo token={{secret:pet_test_token}}\ntoken=s3cr3t\n", stdout.String())
+
+	// The snippet file keeps the reference
+	var snippets snippet.Snippets
+	assert.NoError(t, snippets.Load(false))
+	assert.Equal(t, "echo token={{secret:pet_test_token}}", snippets.Snippets[0].Command)
+}
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/knqyf263/pet/snippet"
+	"github.com/spf13/cobra"
+)
+
+// historyCmd represents the history command
+var historyCmd = &cobra.Command{
+	Use:   "history [version]",
+	Short: "Show the versions of the snippet files",
+	Long: `Show the versions of the snippet files kept in the journal, newest first,
+or the snippets added, removed and changed by a version`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: history,
+}
+
+func history(cmd *cobra.Command, args []string) (err error) {
+	return _history(os.Stdout, args)
+}
+
+func _history(out io.Writer, args []string) error {
+	journal, err := snippet.LoadJournal()
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 0 {
+		for i := len(journal.Versions) - 1; i >= 0; i-- {
+			v := journal.Versions[i]
+			diff, err := journal.Diff(v)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "%5d  %s  %-8s %s  %s\n", v.Version, v.Time.Local().Format("2006-01-02 15:04:05"),
+				versionReason(v), v.File, diffSummary(diff))
+		}
+		return nil
+	}
+
+	version, err := parseVersion(args[0])
+	if err != nil {
+		return err
+	}
+	v, ok := journal.Find(version)
+	if !ok {
+		return fmt.Errorf("version %d not found", version)
+	}
+	diff, err := journal.Diff(v)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Version %d of %s, %s at %s\n", v.Version, v.File, versionReason(v),
+		v.Time.Local().Format("2006-01-02 15:04:05"))
+	for _, s := range diff.Added {
+		fmt.Fprintf(out, "%s %s\n", color.HiGreenString("+"), snippetLine(s))
+	}
+	for _, s := range diff.Removed {
+		fmt.Fprintf(out, "%s %s\n", color.HiRedString("-"), snippetLine(s))
+	}
+	for _, s := range diff.Changed {
+		fmt.Fprintf(out, "%s %s\n", color.HiYellowString("~"), snippetLine(s))
+	}
+	return nil
+}
+
+// parseVersion parses the version number given as argument
+func parseVersion(arg string) (int, error) {
+	version, err := strconv.Atoi(arg)
+	if err != nil || version <= 0 {
+		return 0, fmt.Errorf("invalid version: %s", arg)
+	}
+	return version, nil
+}
+
+// versionReason describes why a version was written
+func versionReason(v snippet.Version) string {
+	reason := v.Reason
+	if v.Deleted {
+		reason += ", deleted"
+	}
+	if v.Restored != 0 {
+		reason += fmt.Sprintf(" of %d", v.Restored)
+	}
+	return reason
+}
+
+// diffSummary counts the snippets added, removed and changed by a version
+func diffSummary(diff snippet.VersionDiff) string {
+	return fmt.Sprintf("%s %s %s",
+		color.HiGreenString("+%d", len(diff.Added)),
+		color.HiRedString("-%d", len(diff.Removed)),
+		color.HiYellowString("~%d", len(diff.Changed)))
+}
+
+// snippetLine shows a snippet on one line
+func snippetLine(s snippet.SnippetInfo) string {
+	return fmt.Sprintf("[%s]: %s", s.Description, strings.Replace(s.Command, "\n", "\\n", -1))
+}
+
+func init() {
+	RootCmd.AddCommand(historyCmd)
+}
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/knqyf263/pet/snippet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistoryUndoRestore(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+	defer func(noColor bool) { color.NoColor = noColor }(color.NoColor)
+	color.NoColor = true
+
+	var snippets snippet.Snippets
+	require.NoError(t, snippets.Load(false))
+	snippets.Remove(snippets.Snippets[0].ID)
+	require.NoError(t, snippets.Save())
+
+	var stdout bytes.Buffer
+	require.NoError(t, _history(&stdout, nil))
+	lines := strings.Split(strings.TrimRight(stdout.String(), "\n"), "\n")
+	require.Len(t, lines, 2)
+	assert.True(t, strings.HasPrefix(lines[0], "    2  "))
+	assert.Contains(t, lines[0], "save ")
+	assert.True(t, strings.HasSuffix(lines[0], "+0 -1 ~0"))
+	assert.True(t, strings.HasPrefix(lines[1], "    1  "))
+	assert.Contains(t, lines[1], "original")
+
+	stdout.Reset()
+	require.NoError(t, _history(&stdout, []string{"2"}))
+	assert.Contains(t, stdout.String(), "- [main snippet 1]: echo main\n")
+
+	stdout.Reset()
+	require.NoError(t, _undo(&stdout))
+	assert.Contains(t, stdout.String(), "to version 1\n")
+	var restored snippet.Snippets
+	require.NoError(t, restored.Load(false))
+	assert.Len(t, restored.Snippets, 2)
+
+	stdout.Reset()
+	require.NoError(t, _restore(&stdout, "2"))
+	var again snippet.Snippets
+	require.NoError(t, again.Load(false))
+	assert.Len(t, again.Snippets, 1)
+
+	assert.EqualError(t, _restore(&stdout, "x"), "invalid version: x")
+}
@@ -51,6 +51,8 @@ func list(cmd *cobra.Command, args []string) error {
 				fmt.Fprintf(color.Output, "%12s %s\n",
 					color.RedString("   Filename:"), snippet.Filename)
 			}
+			fmt.Fprintf(color.Output, "%12s %s\n",
+				color.HiBlueString("         ID:"), snippet.ID)
 			fmt.Fprintf(color.Output, "%12s %s\n",
 				color.HiGreenString("Description:"), snippet.Description)
 			if strings.Contains(snippet.Command, "\n") {
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
@@ -147,10 +148,17 @@ func scanMultiLine(prompt string, secondMessage string, out io.Writer, in io.Rea
 }
 
 // createAndEditSnippet creates and saves a given snippet to the main snippet file
-// then opens the configured editor to edit the snippet file at startLine.
-func createAndEditSnippet(newSnippet snippet.SnippetInfo, snippets snippet.Snippets, startLine int) error {
-	snippets.Snippets = append(snippets.Snippets, newSnippet)
-	if err := snippets.Save(); err != nil {
+// then opens the configured editor to edit the snippet file at the new snippet.
+func createAndEditSnippet(newSnippet snippet.SnippetInfo) error {
+	unlock, err := snippet.Lock()
+	if err != nil {
+		return err
+	}
+	// The new snippet is appended below the current end of the file
+	startLine := countSnippetLines() + 3
+	err = appendSnippet(newSnippet)
+	unlock()
+	if err != nil {
 		return err
 	}
 
@@ -162,16 +170,32 @@ func createAndEditSnippet(newSnippet snippet.SnippetInfo, snippets snippet.Snipp
 	}
 
 	editor := config.Conf.General.Editor
-	err = editFile(editor, snippetFilePath, startLine)
+	err = editSnippetFile(editor, snippetFilePath, startLine)
 	if err != nil {
 		return err
 	}
+	if err := recordFileVersion(snippetFilePath, snippet.ReasonEdit); err != nil {
+		return err
+	}
 
-	if config.Conf.Gist.AutoSync {
-		return petSync.AutoSync(snippetFilePath)
+	return petSync.AfterChange(configFile)
+}
+
+// appendSnippet adds a snippet to the main snippet file. The file is read again
+// under the lock, so that snippets saved by another pet meanwhile are kept.
+func appendSnippet(newSnippet snippet.SnippetInfo) error {
+	unlock, err := snippet.Lock()
+	if err != nil {
+		return err
 	}
+	defer unlock()
 
-	return nil
+	var snippets snippet.Snippets
+	if err := snippets.Load(false); err != nil {
+		return err
+	}
+	snippets.Snippets = append(snippets.Snippets, newSnippet)
+	return snippets.Save()
 }
 
 func countSnippetLines() int {
@@ -181,12 +205,12 @@ func countSnippetLines() int {
 		panic(fmt.Sprintf("Error getting snippet file path: %v", err.Error()))
 	}
 
-	f, err := os.Open(path.Get())
+	data, err := snippet.ReadFile(path.Get())
 	if err != nil {
 		panic("Snippet file must be specified - could not read snippet file.")
 	}
 
-	lineCount, err := CountLines(f)
+	lineCount, err := CountLines(bytes.NewReader(data))
 	if err != nil {
 		panic("Error counting lines in snippet file")
 	}
@@ -206,14 +230,12 @@ func _new(in io.ReadCloser, out io.Writer, args []string) (err error) {
 	var description string
 	var tags []string
 
-	// Load snippets from the main file only
+	// Fail before prompting if the main snippet file cannot be loaded
 	var snippets snippet.Snippets
 	if err := snippets.Load(false); err != nil {
 		return err
 	}
 
-	lineCount := countSnippetLines()
-
 	if len(args) > 0 {
 		command = strings.Join(args, " ")
 		fmt.Fprintf(color.Output, "%s %s\n", color.HiYellowString("Command>"), command)
@@ -226,13 +248,16 @@ func _new(in io.ReadCloser, out io.Writer, args []string) (err error) {
 			)
 		} else if config.Flag.UseEditor {
 			// Create and save empty snippet
+			now := snippet.Now()
 			newSnippet := snippet.SnippetInfo{
 				Description: description,
 				Command:     command,
 				Tag:         tags,
+				CreatedAt:   now,
+				UpdatedAt:   now,
 			}
 
-			return createAndEditSnippet(newSnippet, snippets, lineCount+3)
+			return createAndEditSnippet(newSnippet)
 		} else {
 			command, err = scan(color.HiYellowString("Command> "), out, in, false)
 		}
@@ -257,37 +282,25 @@ func _new(in io.ReadCloser, out io.Writer, args []string) (err error) {
 		}
 	}
 
-	for _, s := range snippets.Snippets {
-		if s.Description == description {
-			return fmt.Errorf("snippet [%s] already exists", description)
-		}
-	}
-
 	if config.Conf.General.SnippetFile != "" {
 		filename = config.Conf.General.SnippetFile
 	}
 
+	now := snippet.Now()
 	newSnippet := snippet.SnippetInfo{
 		Filename:    filename,
 		Description: description,
 		Command:     command,
 		Tag:         tags,
+		CreatedAt:   now,
+		UpdatedAt:   now,
 	}
 
-	snippets.Snippets = append(snippets.Snippets, newSnippet)
-	if err = snippets.Save(); err != nil {
+	if err = appendSnippet(newSnippet); err != nil {
 		return err
 	}
 
-	if config.Conf.Gist.AutoSync {
-		filePath, err := path.NewAbsolutePath(filename)
-		if err != nil {
-			return err
-		}
-		return petSync.AutoSync(filePath)
-	}
-
-	return nil
+	return petSync.AfterChange(configFile)
 }
 
 func init() {
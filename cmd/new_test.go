@@ -207,6 +207,9 @@ func TestNew_SnippetCreationWithSnippetDirectory(t *testing.T) {
 	if newSnippet.Command != "echo new command" {
 		t.Errorf("Expected new command to be 'echo new command', got '%s'", newSnippet.Command)
 	}
+	if newSnippet.CreatedAt.IsZero() || newSnippet.UpdatedAt.IsZero() {
+		t.Errorf("Expected new snippet to have creation and modification times")
+	}
 
 	// Ensure the snippet files in the directories remain unchanged
 	var unchangedDirSnippets1, unchangedDirSnippets2 snippet.Snippets
@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"io"
+	"os"
+
+	"github.com/knqyf263/pet/snippet"
+	petSync "github.com/knqyf263/pet/sync"
+	"github.com/spf13/cobra"
+)
+
+// restoreCmd represents the restore command
+var restoreCmd = &cobra.Command{
+	Use:   "restore <version>",
+	Short: "Restore a snippet file to a version",
+	Long:  `Roll a snippet file back to a version shown by the history command`,
+	Args:  cobra.ExactArgs(1),
+	RunE:  restore,
+}
+
+func restore(cmd *cobra.Command, args []string) (err error) {
+	return _restore(os.Stdout, args[0])
+}
+
+func _restore(out io.Writer, arg string) error {
+	version, err := parseVersion(arg)
+	if err != nil {
+		return err
+	}
+
+	v, err := snippet.Restore(version)
+	if err != nil {
+		return err
+	}
+	printRestored(out, v)
+
+	return petSync.AfterChange(configFile)
+}
+
+func init() {
+	RootCmd.AddCommand(restoreCmd)
+}
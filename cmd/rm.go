@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/knqyf263/pet/config"
+	"github.com/knqyf263/pet/snippet"
+	petSync "github.com/knqyf263/pet/sync"
+	"github.com/spf13/cobra"
+	"gopkg.in/alessio/shellescape.v1"
+)
+
+// rmCmd represents the rm command
+var rmCmd = &cobra.Command{
+	Use:   "rm",
+	Short: "Delete the selected snippets",
+	Long:  `Delete the selected snippets from their snippet files`,
+	RunE:  rm,
+}
+
+func rm(cmd *cobra.Command, args []string) (err error) {
+	return _rm(os.Stdin, os.Stdout)
+}
+
+func _rm(in io.ReadCloser, out io.Writer) (err error) {
+	flag := config.Flag
+
+	var options []string
+	if flag.Query != "" {
+		options = append(options, fmt.Sprintf("--query %s", shellescape.Quote(flag.Query)))
+	}
+
+	selected, err := selectSnippets(options, flag.FilterTag)
+	if err != nil {
+		return err
+	}
+	if len(selected) == 0 {
+		return nil
+	}
+
+	for _, s := range selected {
+		if err := snippet.CheckWritable(s.Filename); err != nil {
+			return err
+		}
+	}
+	for _, s := range selected {
+		fmt.Fprintf(out, "%s %s\n", color.HiRedString("Delete>"), s.Description)
+	}
+
+	if !flag.Force {
+		answer, err := scan(color.HiYellowString("Are you sure? [y/N] "), out, in, true)
+		if err != nil {
+			return err
+		}
+		if !strings.EqualFold(answer, "y") && !strings.EqualFold(answer, "yes") {
+			return CanceledError()
+		}
+	}
+
+	removed, err := removeSnippets(selected)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Deleted %d snippet(s)\n", len(removed))
+
+	return petSync.AfterChange(configFile)
+}
+
+// removeSnippets removes the selected snippets from the snippet files. Removing must not
+// drop snippets saved by another pet since they were listed, so they are loaded again
+// under the lock.
+func removeSnippets(selected []snippet.SnippetInfo) ([]snippet.SnippetInfo, error) {
+	unlock, err := snippet.Lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	var snippets snippet.Snippets
+	if err := snippets.Load(true); err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, s := range selected {
+		ids = append(ids, s.ID)
+	}
+	removed := snippets.Remove(ids...)
+	return removed, snippets.Save()
+}
+
+func init() {
+	RootCmd.AddCommand(rmCmd)
+	rmCmd.Flags().StringVarP(&config.Flag.Query, "query", "q", "",
+		`Initial value for query`)
+	rmCmd.Flags().StringVarP(&config.Flag.FilterTag, "tag", "t", "",
+		`Filter tag`)
+	rmCmd.Flags().StringVarP(&config.Flag.SnippetID, "id", "", "",
+		`Select the snippet with this ID instead of prompting`)
+	rmCmd.Flags().BoolVarP(&config.Flag.Force, "force", "f", false,
+		`Delete without asking for confirmation`)
+}
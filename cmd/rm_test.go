@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/knqyf263/pet/config"
+	"github.com/knqyf263/pet/snippet"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRm_DeletesSnippetByID(t *testing.T) {
+	tempDir, cleanup := setupTestConfig(t)
+	defer cleanup()
+	defer func() { config.Flag.SnippetID = "" }()
+
+	var snippets snippet.Snippets
+	assert.NoError(t, snippets.Load(false))
+	config.Flag.SnippetID = snippets.Snippets[0].ID
+
+	var stdout bytes.Buffer
+	stdin := &MockReadCloser{strings.NewReader("y\n")}
+
+	err := _rm(stdin, &stdout)
+	assert.NoError(t, err)
+
+	var updated snippet.Snippets
+	loadSnippetsFromFile(t, filepath.Join(tempDir, "snippet.toml"), &updated)
+	assert.Len(t, updated.Snippets, 1)
+	assert.Equal(t, "main snippet 2", updated.Snippets[0].Description)
+}
+
+func TestRm_Canceled(t *testing.T) {
+	tempDir, cleanup := setupTestConfig(t)
+	defer cleanup()
+	defer func() { config.Flag.SnippetID = "" }()
+
+	var snippets snippet.Snippets
+	assert.NoError(t, snippets.Load(false))
+	config.Flag.SnippetID = snippets.Snippets[0].ID
+
+	var stdout bytes.Buffer
+	stdin := &MockReadCloser{strings.NewReader("n\n")}
+
+	err := _rm(stdin, &stdout)
+	assert.EqualError(t, err, "canceled")
+
+	var updated snippet.Snippets
+	loadSnippetsFromFile(t, filepath.Join(tempDir, "snippet.toml"), &updated)
+	assert.Len(t, updated.Snippets, 2)
+}
+
+func TestRm_DeletesFromSnippetDirectory(t *testing.T) {
+	tempDir, cleanup := setupTestConfig(t)
+	defer cleanup()
+	defer func() {
+		config.Flag.SnippetID = ""
+		config.Flag.Force = false
+		config.Conf.General.SnippetDirs = nil
+	}()
+
+	snippetDir := filepath.Join(tempDir, "snippets")
+	if err := os.Mkdir(snippetDir, 0755); err != nil {
+		t.Fatalf("Failed to create temp snippet directory: %v", err)
+	}
+	dirSnippetFile := filepath.Join(snippetDir, "dir.toml")
+	saveSnippetsToFile(t, dirSnippetFile, snippet.Snippets{
+		Snippets: []snippet.SnippetInfo{
+			{ID: "dir-1", Description: "dir snippet 1", Command: "echo dir"},
+		},
+	})
+	config.Conf.General.SnippetDirs = []string{snippetDir}
+
+	config.Flag.SnippetID = "dir-1"
+	config.Flag.Force = true
+
+	var stdout bytes.Buffer
+	stdin := &MockReadCloser{strings.NewReader("")}
+
+	err := _rm(stdin, &stdout)
+	assert.NoError(t, err)
+
+	// The directory file is emptied, the main file is left untouched
+	var dirSnippets, mainSnippets snippet.Snippets
+	loadSnippetsFromFile(t, dirSnippetFile, &dirSnippets)
+	loadSnippetsFromFile(t, filepath.Join(tempDir, "snippet.toml"), &mainSnippets)
+	assert.Len(t, dirSnippets.Snippets, 0)
+	assert.Len(t, mainSnippets.Snippets, 2)
+}
+
+func TestRm_RefusesMarkdownSnippets(t *testing.T) {
+	tempDir, cleanup := setupTestConfig(t)
+	defer cleanup()
+	defer func() {
+		config.Flag.SnippetID = ""
+		config.Flag.Force = false
+		config.Conf.General.SnippetDirs = nil
+	}()
+
+	snippetDir := filepath.Join(tempDir, "runbooks")
+	if err := os.Mkdir(snippetDir, 0755); err != nil {
+		t.Fatalf("Failed to create temp snippet directory: %v", err)
+	}
+	runbook := filepath.Join(snippetDir, "deploy.md")
+	content := "# Restart\n\n```sh\nsystemctl restart app\n```\n"
+	if err := os.WriteFile(runbook, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write runbook: %v", err)
+	}
+	config.Conf.General.SnippetDirs = []string{snippetDir}
+
+	var snippets snippet.Snippets
+	assert.NoError(t, snippets.Load(true))
+	var restart snippet.SnippetInfo
+	for _, s := range snippets.Snippets {
+		if s.Filename == runbook {
+			restart = s
+		}
+	}
+	config.Flag.SnippetID = restart.ID
+	config.Flag.Force = true
+
+	// Using the snippet is recorded without touching the runbook
+	assert.NoError(t, markUsed([]snippet.SnippetInfo{restart}))
+
+	var stdout bytes.Buffer
+	err := _rm(&MockReadCloser{strings.NewReader("")}, &stdout)
+	assert.ErrorContains(t, err, "cannot change snippets of "+runbook)
+
+	data, err := os.ReadFile(runbook)
+	assert.NoError(t, err)
+	assert.Equal(t, content, string(data))
+}
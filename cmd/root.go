@@ -27,6 +27,7 @@ import (
 
 	"github.com/knqyf263/pet/config"
 	"github.com/knqyf263/pet/path"
+	petSync "github.com/knqyf263/pet/sync"
 	"github.com/spf13/cobra"
 )
 
@@ -89,4 +90,8 @@ func initConfig() {
 		fmt.Fprintf(os.Stderr, "%v", err)
 		os.Exit(1)
 	}
+
+	if !config.Flag.Background {
+		petSync.ReportBackgroundFailure(os.Stderr)
+	}
 }
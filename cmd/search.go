@@ -47,4 +47,10 @@ func init() {
 		`Filter tag`)
 	searchCmd.Flags().StringVarP(&config.Flag.Delimiter, "delimiter", "d", "; ",
 		`Use delim as the command delimiter character`)
+	searchCmd.Flags().StringVarP(&config.Flag.SnippetID, "id", "", "",
+		`Select the snippet with this ID instead of prompting`)
+	searchCmd.Flags().StringArrayVarP(&config.Flag.Params, "param", "p", nil,
+		`Fill in a parameter without the dialog (key=value, repeatable)`)
+	searchCmd.Flags().BoolVarP(&config.Flag.NoPrompt, "no-prompt", "", false,
+		`Use default values for parameters not given with --param instead of the dialog`)
 }
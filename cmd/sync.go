@@ -2,7 +2,6 @@ package cmd
 
 import (
 	"github.com/knqyf263/pet/config"
-	"github.com/knqyf263/pet/path"
 	petSync "github.com/knqyf263/pet/sync"
 	"github.com/spf13/cobra"
 )
@@ -11,15 +10,38 @@ import (
 var syncCmd = &cobra.Command{
 	Use:   "sync",
 	Short: "Sync snippets",
-	Long:  `Sync snippets with gist/gitlab`,
+	Long:  `Sync snippets with gist/gitlab/git`,
 	RunE:  sync,
 }
 
 func sync(cmd *cobra.Command, args []string) (err error) {
-	filePath, err := path.NewAbsolutePath(config.Conf.General.SnippetFile)
-	return petSync.AutoSync(filePath)
+	flag := config.Flag
+	err = petSync.Run(petSync.Options{
+		Status: flag.SyncStatus,
+		DryRun: flag.DryRun,
+		Push:   flag.Push,
+		Pull:   flag.Pull,
+	})
+	if flag.Background {
+		if recordErr := petSync.RecordBackgroundResult(err); recordErr != nil && err == nil {
+			return recordErr
+		}
+	}
+	return err
 }
 
 func init() {
 	RootCmd.AddCommand(syncCmd)
+	syncCmd.Flags().BoolVarP(&config.Flag.SyncStatus, "status", "", false,
+		`Show the last changes and sync, and what a sync would do`)
+	syncCmd.Flags().BoolVarP(&config.Flag.DryRun, "dry-run", "n", false,
+		`Show the snippets a sync would change without changing anything`)
+	syncCmd.Flags().BoolVarP(&config.Flag.Push, "push", "", false,
+		`Replace the remote snippets with the local ones`)
+	syncCmd.Flags().BoolVarP(&config.Flag.Pull, "pull", "", false,
+		`Replace the local snippets with the remote ones`)
+	// Set by the background sync of auto_sync, which reports its failure on the next run
+	syncCmd.Flags().BoolVarP(&config.Flag.Background, "background", "", false, "")
+	syncCmd.Flags().MarkHidden("background")
+	syncCmd.MarkFlagsMutuallyExclusive("push", "pull")
 }
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/knqyf263/pet/snippet"
+	petSync "github.com/knqyf263/pet/sync"
+	"github.com/spf13/cobra"
+)
+
+// undoCmd represents the undo command
+var undoCmd = &cobra.Command{
+	Use:   "undo",
+	Short: "Undo the last change of the snippet files",
+	Long: `Roll the snippet files changed by the last pet command back to their previous versions
+in the journal. Running undo again rolls back the command before it.`,
+	Args: cobra.NoArgs,
+	RunE: undo,
+}
+
+func undo(cmd *cobra.Command, args []string) (err error) {
+	return _undo(os.Stdout)
+}
+
+func _undo(out io.Writer) error {
+	restored, err := snippet.Undo()
+	if err != nil {
+		return err
+	}
+	for _, v := range restored {
+		printRestored(out, v)
+	}
+
+	return petSync.AfterChange(configFile)
+}
+
+// printRestored tells to which version a file was rolled back
+func printRestored(out io.Writer, v snippet.Version) {
+	if v.Version == 0 {
+		fmt.Fprintf(out, "Deleted %s, which did not exist before\n", v.File)
+		return
+	}
+	fmt.Fprintf(out, "Restored %s to version %d\n", v.File, v.Version)
+}
+
+func init() {
+	RootCmd.AddCommand(undoCmd)
+}
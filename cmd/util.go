@@ -2,10 +2,14 @@ package cmd
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
+	"regexp"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/knqyf263/pet/config"
@@ -14,10 +18,28 @@ import (
 	"github.com/knqyf263/pet/snippet"
 )
 
-func filter(options []string, tag string) (commands []string, err error) {
+// defaultParamTimeout is how long commands computing parameter defaults may run by default
+const defaultParamTimeout = 5 * time.Second
+
+// builtinSelectCmd selects snippets with the built-in finder instead of an external command
+const builtinSelectCmd = "builtin"
+
+// selectSnippets returns the snippets picked by the user with the select command.
+// options are simply the list of arguments to pass to the select command (ex. --query for fzf)
+// tag is used to filter the list of snippets by the tag field in the snippet
+// If a snippet ID is given with --id, the select command is skipped.
+func selectSnippets(options []string, tag string) (selected []snippet.SnippetInfo, err error) {
 	var snippets snippet.Snippets
 	if err := snippets.Load(true); err != nil {
-		return commands, fmt.Errorf("load snippet failed: %v", err)
+		return nil, fmt.Errorf("load snippet failed: %v", err)
+	}
+
+	if config.Flag.SnippetID != "" {
+		s, ok := snippets.FindByID(config.Flag.SnippetID)
+		if !ok {
+			return nil, fmt.Errorf("snippet not found: %s", config.Flag.SnippetID)
+		}
+		return []snippet.SnippetInfo{s}, nil
 	}
 
 	// Filter the snippets by specified tag if any
@@ -33,7 +55,9 @@ func filter(options []string, tag string) (commands []string, err error) {
 		snippets = filteredSnippets
 	}
 
-	snippetTexts := map[string]snippet.SnippetInfo{}
+	// Map each displayed line to the ID of its snippet
+	snippetIDs := map[string]string{}
+	var items []dialog.FinderItem
 	var text string
 	for _, s := range snippets.Snippets {
 		command := s.Command
@@ -55,13 +79,32 @@ func filter(options []string, tag string) (commands []string, err error) {
 		t = strings.Replace(t, "$description", s.Description, 1)
 		t = strings.Replace(t, "$tags", tags, 1)
 
-		snippetTexts[t] = s
+		// Snippets that render the same way are told apart by their ID
+		suffix := ""
+		if _, ok := snippetIDs[t]; ok {
+			suffix = fmt.Sprintf(" (%s)", s.ID)
+		}
+
+		snippetIDs[t+suffix] = s.ID
+		items = append(items, dialog.FinderItem{Text: t + suffix, Tags: s.Tag, Preview: preview(s)})
 		if config.Flag.Color || config.Conf.General.Color {
 			t = strings.Replace(format, "$command", command, 1)
 			t = strings.Replace(t, "$description", color.HiRedString(s.Description), 1)
 			t = strings.Replace(t, "$tags", color.HiCyanString(tags), 1)
 		}
-		text += t + "\n"
+		text += t + suffix + "\n"
+	}
+
+	if config.Conf.General.SelectCmd == builtinSelectCmd {
+		picked, err := dialog.Find(items, config.Flag.Query)
+		if err != nil {
+			return nil, err
+		}
+		for _, idx := range picked {
+			s, _ := snippets.FindByID(snippetIDs[items[idx].Text])
+			selected = append(selected, s)
+		}
+		return selected, nil
 	}
 
 	var buf bytes.Buffer
@@ -72,91 +115,257 @@ func filter(options []string, tag string) (commands []string, err error) {
 		return nil, nil
 	}
 
-	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
-	var params [][2]string
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+		id, ok := snippetIDs[line]
+		if !ok {
+			continue
+		}
+		s, _ := snippets.FindByID(id)
+		selected = append(selected, s)
+	}
+	return selected, nil
+}
+
+func filter(options []string, tag string) (commands []string, err error) {
+	snippets, err := selectSnippets(options, tag)
+	if err != nil {
+		return nil, err
+	}
 
-	// If only one line is selected, search for params in the command
-	if len(lines) == 1 {
-		snippetInfo := snippetTexts[lines[0]]
-		params = dialog.SearchForParams(snippetInfo.Command)
-	} else {
-		params = nil
+	if err := markUsed(snippets); err != nil {
+		return nil, err
+	}
+
+	// If only one snippet is selected, search for params in the command
+	var params [][2]string
+	if len(snippets) == 1 {
+		params = dialog.SearchForParams(snippets[0].Command)
 	}
 
 	if params != nil {
-		snippetInfo := snippetTexts[lines[0]]
-		dialog.CurrentCommand = snippetInfo.Command
-		dialog.GenerateParamsLayout(params, dialog.CurrentCommand)
+		values, err := parseParamFlags(config.Flag.Params)
+		if err != nil {
+			return nil, err
+		}
+
+		params, err = resolveDynamicParams(params, snippets[0].Params, values)
+		if err != nil {
+			return nil, err
+		}
+
+		command, missing, err := dialog.FillParams(snippets[0].Command, params, values, config.Flag.NoPrompt, snippets[0].Params)
+		if err != nil {
+			return nil, err
+		}
+		if len(missing) == 0 {
+			// Values given as flags are remembered like values entered in the dialog
+			given := map[string]string{}
+			for _, pair := range params {
+				if value, ok := values[pair[0]]; ok {
+					given[pair[0]] = value
+				}
+			}
+			if err := snippet.RecordParams(snippets[0], given); err != nil {
+				return nil, err
+			}
+			return []string{command}, nil
+		} else if config.Flag.NoPrompt {
+			return nil, fmt.Errorf("missing value for parameter(s): %s", strings.Join(missing, ", "))
+		}
+
+		// Previously entered values are offered after the defaults
+		history, err := snippet.LoadParamHistory()
+		if err != nil {
+			return nil, err
+		}
+		params = withParamHistory(params, history.Values(snippets[0].ID))
+
+		// Values given as flags are offered as defaults in the dialog
+		for i, pair := range params {
+			if value, ok := values[pair[0]]; ok {
+				params[i][1] = value
+			}
+		}
+
+		dialog.CurrentCommand = snippets[0].Command
+		dialog.FilledParams = nil
+		dialog.GenerateParamsLayout(params, dialog.CurrentCommand, snippets[0].Params)
+		if dialog.FilledParams != nil {
+			entered := map[string]string{}
+			for _, pair := range params {
+				entered[pair[0]] = dialog.FilledParams[pair[0]]
+			}
+			if err := snippet.RecordParams(snippets[0], entered); err != nil {
+				return nil, err
+			}
+		}
 		res := []string{dialog.FinalCommand}
 		return res, nil
 	}
-	for _, line := range lines {
-		snippetInfo := snippetTexts[line]
+	for _, snippetInfo := range snippets {
 		commands = append(commands, fmt.Sprint(snippetInfo.Command))
 	}
 	return commands, nil
 }
 
-// selectFile returns a snippet file path from the list of snippets
-// options are simply the list of arguments to pass to the select command (ex. --query for fzf)
-// tag is used to filter the list of snippets by the tag field in the snippet
-func selectFile(options []string, tag string) (snippetFile path.AbsolutePath, err error) {
-	var snippets snippet.Snippets
-	if err := snippets.Load(true); err != nil {
-		return snippetFile, fmt.Errorf("load snippet failed: %v", err)
-	}
+// dynamicDefaultRegex matches a default value computed by a command, ex. <branch=$(git branch --show-current)>
+var dynamicDefaultRegex = regexp.MustCompile(`^\$\((.+)\)$`)
 
-	// Filter the snippets by specified tag if any
-	if 0 < len(tag) {
-		var filteredSnippets snippet.Snippets
-		for _, snippet := range snippets.Snippets {
-			for _, t := range snippet.Tag {
-				if tag == t {
-					filteredSnippets.Snippets = append(filteredSnippets.Snippets, snippet)
-				}
+// resolveDynamicParams runs the commands computing the default values of params.
+// A default value written as $(command) is replaced by the output of the command,
+// and the output lines of the suggest command of a param are offered as multiple default values.
+// Params already given a value are skipped.
+func resolveDynamicParams(params [][2]string, specs map[string]snippet.ParamSpec, values map[string]string) ([][2]string, error) {
+	resolved := make([][2]string, len(params))
+	copy(resolved, params)
+
+	for i, pair := range resolved {
+		parameterKey, parameterValue := pair[0], pair[1]
+		if _, ok := values[parameterKey]; ok {
+			continue
+		}
+
+		if match := dynamicDefaultRegex.FindStringSubmatch(parameterValue); match != nil {
+			out, err := commandOutput(match[1])
+			if err != nil {
+				return nil, fmt.Errorf("failed to compute the default value of parameter %s: %v", parameterKey, err)
 			}
+			parameterValue = strings.TrimSpace(out)
 		}
-		snippets = filteredSnippets
+
+		suggest := specs[parameterKey].Suggest
+		if suggest == "" {
+			resolved[i][1] = parameterValue
+			continue
+		}
+
+		out, err := commandOutput(suggest)
+		if err != nil {
+			return nil, fmt.Errorf("failed to suggest values for parameter %s: %v", parameterKey, err)
+		}
+
+		// The default value comes first, followed by the suggestions
+		var options []string
+		if parameterValue != "" {
+			options = append(options, parameterValue)
+		}
+		for _, line := range strings.Split(out, "\n") {
+			line = strings.TrimSpace(line)
+			if line != "" && !slices.Contains(options, line) {
+				options = append(options, line)
+			}
+		}
+
+		resolved[i][1] = dialog.MultipleDefaultValues(options)
 	}
+	return resolved, nil
+}
 
-	// Create a map of (desc, command, tags) string to SnippetInfo
-	snippetTexts := map[string]snippet.SnippetInfo{}
-	var text string
-	for _, s := range snippets.Snippets {
-		command := s.Command
-		if strings.ContainsAny(command, "\n") {
-			command = strings.Replace(command, "\n", "\\n", -1)
+// withParamHistory offers the values previously entered for params after their default values
+func withParamHistory(params [][2]string, history map[string][]string) [][2]string {
+	for i, pair := range params {
+		parameterKey, parameterValue := pair[0], pair[1]
+		if len(history[parameterKey]) == 0 {
+			continue
 		}
-		t := fmt.Sprintf("[%s]: %s", s.Description, command)
 
-		tags := ""
-		for _, tag := range s.Tag {
-			tags += fmt.Sprintf(" #%s", tag)
+		var options []string
+		for _, v := range dialog.DefaultValues(parameterValue) {
+			if v != "" {
+				options = append(options, v)
+			}
 		}
-		t += tags
+		for _, v := range history[parameterKey] {
+			if !slices.Contains(options, v) {
+				options = append(options, v)
+			}
+		}
+		params[i][1] = dialog.MultipleDefaultValues(options)
+	}
+	return params
+}
 
-		snippetTexts[t] = s
-		text += t + "\n"
+// commandOutput runs a command and returns its output, the command is killed after ParamTimeout
+func commandOutput(command string) (string, error) {
+	timeout := time.Duration(config.Conf.General.ParamTimeout) * time.Second
+	if timeout <= 0 {
+		timeout = defaultParamTimeout
 	}
 
-	// Build the select command with options and run it
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
 	var buf bytes.Buffer
-	selectCmd := fmt.Sprintf("%s %s",
-		config.Conf.General.SelectCmd, strings.Join(options, " "))
-	err = run(selectCmd, strings.NewReader(text), &buf)
+	if err := runContext(ctx, command, strings.NewReader(""), &buf); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return "", fmt.Errorf("%s timed out after %s", command, timeout)
+		}
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// parseParamFlags parses the key=value pairs given with --param
+func parseParamFlags(flags []string) (map[string]string, error) {
+	values := map[string]string{}
+	for _, f := range flags {
+		key, value, ok := strings.Cut(f, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid parameter %q, expected key=value", f)
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
+// preview returns the full snippet as shown in the preview pane of the built-in finder
+func preview(s snippet.SnippetInfo) string {
+	text := fmt.Sprintf("Description: %s\n\n%s\n", s.Description, s.Command)
+	if len(s.Tag) > 0 {
+		text += fmt.Sprintf("\nTag: %s\n", strings.Join(s.Tag, " "))
+	}
+	if s.Output != "" {
+		text += fmt.Sprintf("\nOutput:\n%s\n", s.Output)
+	}
+	return text
+}
+
+// markUsed records a use of the given snippets in the local state file.
+// Snippet files are left alone, so that using a snippet changes nothing to sync.
+func markUsed(used []snippet.SnippetInfo) error {
+	if len(used) == 0 {
+		return nil
+	}
+
+	// Uses are counted one at a time by concurrent pets
+	unlock, err := snippet.Lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	var ids []string
+	for _, s := range used {
+		ids = append(ids, s.ID)
+	}
+	return snippet.RecordUsage(ids...)
+}
+
+// selectFile returns a snippet file path from the list of snippets
+// options are simply the list of arguments to pass to the select command (ex. --query for fzf)
+// tag is used to filter the list of snippets by the tag field in the snippet
+func selectFile(options []string, tag string) (snippetFile path.AbsolutePath, err error) {
+	snippets, err := selectSnippets(options, tag)
 	if err != nil {
 		return nil, err
 	}
 
-	// Parse the selected line and return the corresponding snippet file
-	// We might have multiple lines selected, but we only care about the first one
-	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
-	if len(lines) == 0 {
+	// We might have multiple snippets selected, but we only care about the first one
+	if len(snippets) == 0 {
 		return nil, errors.New("no snippet file selected")
 	}
 
-	snippetInfo := snippetTexts[lines[0]]
-	snippetFile, err = path.NewAbsolutePath(snippetInfo.Filename)
+	snippetFile, err = path.NewAbsolutePath(snippets[0].Filename)
 	if err != nil {
 		return nil, err
 	}
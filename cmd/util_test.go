@@ -0,0 +1,83 @@
+//go:build !windows
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/go-test/deep"
+	"github.com/knqyf263/pet/config"
+	"github.com/knqyf263/pet/snippet"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveDynamicParams(t *testing.T) {
+	params := [][2]string{
+		{"static", "value"},
+		{"branch", "$(echo main)"},
+		{"container", ""},
+		{"env", "dev"},
+		{"given", "$(exit 1)"},
+	}
+	specs := map[string]snippet.ParamSpec{
+		"container": {Suggest: "printf 'web\\ndb\\n'"},
+		"env":       {Suggest: "printf 'prod\\ndev\\n'"},
+	}
+	values := map[string]string{"given": "by flag"}
+
+	got, err := resolveDynamicParams(params, specs, values)
+	assert.NoError(t, err)
+
+	want := [][2]string{
+		{"static", "value"},
+		{"branch", "main"},
+		{"container", "|_web_||_db_|"},
+		{"env", "|_dev_||_prod_|"},
+		{"given", "$(exit 1)"},
+	}
+	if diff := deep.Equal(want, got); diff != nil {
+		t.Fatal(diff)
+	}
+
+	// The params of the snippet are left untouched
+	assert.Equal(t, "$(echo main)", params[1][1])
+}
+
+func TestResolveDynamicParams_CommandFails(t *testing.T) {
+	params := [][2]string{{"branch", "$(exit 1)"}}
+
+	_, err := resolveDynamicParams(params, nil, nil)
+	assert.EqualError(t, err, "failed to compute the default value of parameter branch: exit status 1")
+}
+
+func TestCommandOutput_Timeout(t *testing.T) {
+	defer func() { config.Conf.General.ParamTimeout = 0 }()
+	config.Conf.General.ParamTimeout = 1
+
+	_, err := commandOutput("sleep 3")
+	assert.EqualError(t, err, "sleep 3 timed out after 1s")
+}
+
+func TestWithParamHistory(t *testing.T) {
+	params := [][2]string{
+		{"host", "localhost"},
+		{"port", "|_80_||_443_|"},
+		{"path", ""},
+		{"user", "root"},
+	}
+	history := map[string][]string{
+		"host": {"example.com", "localhost"},
+		"port": {"8080"},
+		"path": {"/tmp"},
+	}
+
+	want := [][2]string{
+		{"host", "|_localhost_||_example.com_|"},
+		{"port", "|_80_||_443_||_8080_|"},
+		{"path", "|_/tmp_|"},
+		{"user", "root"},
+	}
+	if diff := deep.Equal(want, withParamHistory(params, history)); diff != nil {
+		t.Fatal(diff)
+	}
+}
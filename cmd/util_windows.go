@@ -3,28 +3,39 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
 	"syscall"
+	"time"
 
 	"github.com/knqyf263/pet/config"
 	"github.com/knqyf263/pet/path"
 )
 
 func run(command string, r io.Reader, w io.Writer) error {
+	return runContext(context.Background(), command, r, w)
+}
+
+// runContext runs a command like run, the command is killed when the context is done
+func runContext(ctx context.Context, command string, r io.Reader, w io.Writer) error {
 	var cmd *exec.Cmd
 	if len(config.Conf.General.Cmd) > 0 {
 		line := append(config.Conf.General.Cmd, command)
-		cmd = exec.Command(line[0], line[1:]...)
+		cmd = exec.CommandContext(ctx, line[0], line[1:]...)
 	} else {
-		cmd = exec.Command("cmd.exe")
+		cmd = exec.CommandContext(ctx, "cmd.exe")
 		cmd.SysProcAttr = &syscall.SysProcAttr{CmdLine: fmt.Sprintf("/c \"%s\"", command)}
 	}
 	cmd.Stderr = os.Stderr
 	cmd.Stdout = w
 	cmd.Stdin = r
+	// Children left by a killed shell must not keep us waiting for their output
+	if _, ok := ctx.Deadline(); ok {
+		cmd.WaitDelay = time.Second
+	}
 	return cmd.Run()
 }
 
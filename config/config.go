@@ -21,20 +21,35 @@ type Config struct {
 	Gist    GistConfig
 	GitLab  GitLabConfig
 	GHEGist GHEGistConfig
+	Git     GitConfig
+	S3      S3Config
+	WebDAV  WebDAVConfig
+	Gitea   GiteaConfig
+
+	Encryption EncryptionConfig
+	Secret     SecretConfig
 }
 
 // GeneralConfig is a struct of general config
 type GeneralConfig struct {
-	SnippetFile string
-	SnippetDirs []string
-	Editor      string
-	Column      int
-	SelectCmd   string
-	Backend     string
-	SortBy      string
-	Color       bool
-	Format      string
-	Cmd         []string
+	SnippetFile      string
+	SnippetDirs      []string
+	StateFile        string
+	Editor           string
+	Column           int
+	SelectCmd        string
+	Backend          string
+	BackgroundSync   bool
+	SortBy           string
+	Color            bool
+	Format           string
+	Cmd              []string
+	ParamTimeout     int
+	ParamHistoryFile string
+	ParamHistorySize int
+	LockTimeout      int
+	JournalDir       string
+	JournalSize      int
 }
 
 // GistConfig is a struct of config for Gist
@@ -68,6 +83,62 @@ type GHEGistConfig struct {
 	AutoSync    bool `toml:"auto_sync"`
 }
 
+// GitConfig is a struct of config for a git repository
+type GitConfig struct {
+	Remote   string
+	Branch   string
+	Dir      string
+	AutoSync bool `toml:"auto_sync"`
+}
+
+// S3Config is a struct of config for S3 compatible object storage
+type S3Config struct {
+	Bucket          string
+	Prefix          string
+	Endpoint        string
+	Region          string
+	PathStyle       bool   `toml:"path_style"`
+	FileName        string `toml:"file_name"`
+	AccessKeyID     string `toml:"access_key_id"`
+	SecretAccessKey string `toml:"secret_access_key"`
+	AutoSync        bool   `toml:"auto_sync"`
+}
+
+// WebDAVConfig is a struct of config for a WebDAV server
+type WebDAVConfig struct {
+	Url      string
+	FileName string `toml:"file_name"`
+	User     string
+	Password string
+	Token    string
+	AutoSync bool `toml:"auto_sync"`
+}
+
+// GiteaConfig is a struct of config for a repository on Gitea or Forgejo
+type GiteaConfig struct {
+	Url         string
+	AccessToken string `toml:"access_token"`
+	Repo        string
+	Branch      string
+	Path        string // directory of the snippet files, or the path of the main snippet file
+	FileName    string `toml:"file_name"` // name of the main snippet file if Path is a directory
+	AutoSync    bool   `toml:"auto_sync"`
+	SkipSsl     bool   `toml:"skip_ssl"`
+}
+
+// EncryptionConfig is a struct of config for encrypting snippets
+type EncryptionConfig struct {
+	Sync              bool   // encrypt the snippets uploaded by pet sync
+	Files             bool   // encrypt the snippet files on disk
+	PassphraseCommand string `toml:"passphrase_command"`
+}
+
+// SecretConfig is a struct of config for the secrets referenced by snippets
+type SecretConfig struct {
+	File   string // TOML file of name = "value" pairs (default: secrets.toml in the config directory)
+	Helper string // command printing the secret whose name is appended to it
+}
+
 // Flag is global flag variable
 var Flag FlagConfig
 
@@ -83,7 +154,17 @@ type FlagConfig struct {
 	Tag          bool
 	UseMultiLine bool
 	UseEditor    bool
-	Silent		 bool
+	Silent       bool
+	SnippetID    string
+	Force        bool
+	EditSnippet  bool
+	Params       []string
+	NoPrompt     bool
+	SyncStatus   bool
+	DryRun       bool
+	Push         bool
+	Pull         bool
+	Background   bool
 }
 
 // Load loads a config toml
@@ -146,6 +227,14 @@ func (cfg *Config) Load(filePath path.AbsolutePath) error {
 	cfg.GitLab.FileName = "pet-snippet.toml"
 	cfg.GitLab.Visibility = "private"
 
+	cfg.Git.Branch = "main"
+
+	cfg.S3.FileName = "pet-snippet.toml"
+
+	cfg.WebDAV.FileName = "pet-snippet.toml"
+
+	cfg.Gitea.FileName = "pet-snippet.toml"
+
 	return toml.NewEncoder(f).Encode(cfg)
 }
 
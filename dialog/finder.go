@@ -0,0 +1,307 @@
+package dialog
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/awesome-gocui/gocui"
+)
+
+const (
+	finderQueryView   = "finder-query"
+	finderListView    = "finder-list"
+	finderPreviewView = "finder-preview"
+)
+
+// FinderItem is an entry offered by the built-in finder
+type FinderItem struct {
+	// Text is shown in the list and fuzzy matched against the query
+	Text string
+	// Tags are matched by query terms starting with '#'
+	Tags []string
+	// Preview is shown next to the list for the current item
+	Preview string
+}
+
+// finder holds the state of the built-in finder
+type finder struct {
+	items    []FinderItem
+	query    string
+	matches  []int
+	cursor   int
+	selected map[int]bool
+	picked   []int
+}
+
+func newFinder(items []FinderItem, query string) *finder {
+	f := &finder{
+		items:    items,
+		selected: map[int]bool{},
+	}
+	f.setQuery(query)
+	return f
+}
+
+// Find lets the user pick items interactively with fuzzy matching
+// and returns the indexes of the picked items in list order.
+// TAB marks multiple items, nothing is returned when the finder is canceled.
+func Find(items []FinderItem, query string) ([]int, error) {
+	g, err := gocui.NewGui(gocui.OutputNormal, false)
+	if err != nil {
+		return nil, err
+	}
+	defer g.Close()
+
+	g.Cursor = true
+	f := newFinder(items, query)
+	g.SetManagerFunc(f.layout)
+
+	if err := f.initKeybindings(g); err != nil {
+		return nil, err
+	}
+
+	if err := g.MainLoop(); err != nil && err != gocui.ErrQuit {
+		return nil, err
+	}
+	return f.picked, nil
+}
+
+func (f *finder) layout(g *gocui.Gui) error {
+	maxX, maxY := g.Size()
+	listWidth := maxX / 2
+
+	if v, err := g.SetView(finderQueryView, 0, 0, maxX-1, 2, 0); err != nil {
+		if err != gocui.ErrUnknownView {
+			return err
+		}
+		v.Title = "Query (TAB => Mark, ENTER => Select, #tag => Filter by tag)"
+		v.Editable = true
+		v.Editor = gocui.EditorFunc(f.edit)
+		fmt.Fprint(v, f.query)
+		v.SetCursor(len([]rune(f.query)), 0)
+		if _, err := g.SetCurrentView(finderQueryView); err != nil {
+			return err
+		}
+	}
+
+	list, err := g.SetView(finderListView, 0, 3, listWidth-1, maxY-1, 0)
+	if err != nil {
+		if err != gocui.ErrUnknownView {
+			return err
+		}
+		list.Title = "Snippets"
+	}
+	_, height := list.Size()
+	list.Clear()
+	for _, line := range f.visibleLines(height) {
+		fmt.Fprintln(list, line)
+	}
+
+	preview, err := g.SetView(finderPreviewView, listWidth, 3, maxX-1, maxY-1, 0)
+	if err != nil {
+		if err != gocui.ErrUnknownView {
+			return err
+		}
+		preview.Title = "Preview"
+		preview.Wrap = true
+	}
+	preview.Clear()
+	if item, ok := f.current(); ok {
+		fmt.Fprint(preview, item.Preview)
+	}
+	return nil
+}
+
+func (f *finder) initKeybindings(g *gocui.Gui) error {
+	bindings := []struct {
+		key     gocui.Key
+		handler func(*gocui.Gui, *gocui.View) error
+	}{
+		{gocui.KeyArrowDown, func(*gocui.Gui, *gocui.View) error { f.move(1); return nil }},
+		{gocui.KeyCtrlN, func(*gocui.Gui, *gocui.View) error { f.move(1); return nil }},
+		{gocui.KeyArrowUp, func(*gocui.Gui, *gocui.View) error { f.move(-1); return nil }},
+		{gocui.KeyCtrlP, func(*gocui.Gui, *gocui.View) error { f.move(-1); return nil }},
+		{gocui.KeyTab, func(*gocui.Gui, *gocui.View) error { f.toggle(); return nil }},
+		{gocui.KeyEnter, func(*gocui.Gui, *gocui.View) error { f.accept(); return gocui.ErrQuit }},
+		{gocui.KeyEsc, quit},
+		{gocui.KeyCtrlC, quit},
+	}
+
+	for _, b := range bindings {
+		if err := g.SetKeybinding(finderQueryView, b.key, gocui.ModNone, b.handler); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// edit updates the query as the user types and filters the items again
+func (f *finder) edit(v *gocui.View, key gocui.Key, ch rune, mod gocui.Modifier) {
+	switch {
+	case ch != 0 && mod == 0:
+		v.EditWrite(ch)
+	case key == gocui.KeySpace:
+		v.EditWrite(' ')
+	case key == gocui.KeyBackspace || key == gocui.KeyBackspace2:
+		v.EditDelete(true)
+	case key == gocui.KeyDelete:
+		v.EditDelete(false)
+	case key == gocui.KeyCtrlU:
+		v.Clear()
+		v.SetCursor(0, 0)
+	case key == gocui.KeyArrowLeft:
+		v.MoveCursor(-1, 0)
+	case key == gocui.KeyArrowRight:
+		v.MoveCursor(1, 0)
+	default:
+		return
+	}
+	f.setQuery(strings.TrimRight(v.Buffer(), "\n"))
+}
+
+func (f *finder) setQuery(query string) {
+	f.query = query
+	f.matches = filterItems(f.items, query)
+	f.cursor = 0
+}
+
+func (f *finder) move(delta int) {
+	if len(f.matches) == 0 {
+		return
+	}
+	f.cursor = (f.cursor + delta + len(f.matches)) % len(f.matches)
+}
+
+// toggle marks or unmarks the current item and moves to the next one
+func (f *finder) toggle() {
+	if len(f.matches) == 0 {
+		return
+	}
+	idx := f.matches[f.cursor]
+	if f.selected[idx] {
+		delete(f.selected, idx)
+	} else {
+		f.selected[idx] = true
+	}
+	f.move(1)
+}
+
+// accept picks the marked items, or the current item if none is marked
+func (f *finder) accept() {
+	f.picked = nil
+	for idx := range f.selected {
+		f.picked = append(f.picked, idx)
+	}
+	sort.Ints(f.picked)
+
+	if len(f.picked) == 0 && len(f.matches) > 0 {
+		f.picked = []int{f.matches[f.cursor]}
+	}
+}
+
+func (f *finder) current() (FinderItem, bool) {
+	if len(f.matches) == 0 {
+		return FinderItem{}, false
+	}
+	return f.items[f.matches[f.cursor]], true
+}
+
+// visibleLines renders the matches fitting in height lines, keeping the cursor visible
+func (f *finder) visibleLines(height int) (lines []string) {
+	top := 0
+	if height > 0 && f.cursor >= height {
+		top = f.cursor - height + 1
+	}
+
+	for i := top; i < len(f.matches) && (height <= 0 || i < top+height); i++ {
+		idx := f.matches[i]
+		pointer, mark := " ", " "
+		if i == f.cursor {
+			pointer = ">"
+		}
+		if f.selected[idx] {
+			mark = "*"
+		}
+		lines = append(lines, pointer+mark+f.items[idx].Text)
+	}
+	return lines
+}
+
+// filterItems returns the indexes of the items matching every term of the query, best matches first.
+// Terms starting with '#' match the beginning of a tag, other terms are fuzzy matched against the text.
+func filterItems(items []FinderItem, query string) []int {
+	terms := strings.Fields(query)
+	scores := map[int]int{}
+	var matches []int
+
+	for i, item := range items {
+		score, ok := 0, true
+		for _, term := range terms {
+			if tag, isTag := strings.CutPrefix(term, "#"); isTag && tag != "" {
+				if !hasTagPrefix(item.Tags, tag) {
+					ok = false
+					break
+				}
+				continue
+			}
+
+			s, matched := fuzzyMatch(term, item.Text)
+			if !matched {
+				ok = false
+				break
+			}
+			score += s
+		}
+
+		if ok {
+			scores[i] = score
+			matches = append(matches, i)
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return scores[matches[i]] > scores[matches[j]]
+	})
+	return matches
+}
+
+func hasTagPrefix(tags []string, prefix string) bool {
+	for _, tag := range tags {
+		if strings.HasPrefix(strings.ToLower(tag), strings.ToLower(prefix)) {
+			return true
+		}
+	}
+	return false
+}
+
+// fuzzyMatch reports whether all characters of pattern appear in text in order.
+// Consecutive characters and characters at the start of a word score higher.
+// Matching is case insensitive unless the pattern contains upper case characters.
+func fuzzyMatch(pattern, text string) (score int, ok bool) {
+	p := []rune(pattern)
+	t := []rune(text)
+	if !strings.ContainsFunc(pattern, unicode.IsUpper) {
+		p = []rune(strings.ToLower(pattern))
+		t = []rune(strings.ToLower(text))
+	}
+
+	pi, last := 0, -2
+	for ti := 0; ti < len(t) && pi < len(p); ti++ {
+		if t[ti] != p[pi] {
+			continue
+		}
+
+		score++
+		if ti == last+1 {
+			score += 5
+		}
+		if ti == 0 || !unicode.IsLetter(t[ti-1]) && !unicode.IsDigit(t[ti-1]) {
+			score += 3
+		}
+		last = ti
+		pi++
+	}
+	return score, pi == len(p)
+}
@@ -0,0 +1,122 @@
+package dialog
+
+import (
+	"testing"
+
+	"github.com/go-test/deep"
+)
+
+func TestFuzzyMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		text    string
+		wantOk  bool
+	}{
+		{name: "match - exact", pattern: "ping", text: "ping 8.8.8.8", wantOk: true},
+		{name: "match - subsequence", pattern: "dkps", text: "docker ps -a", wantOk: true},
+		{name: "match - case insensitive", pattern: "docker", text: "Docker PS", wantOk: true},
+		{name: "match - empty pattern", pattern: "", text: "anything", wantOk: true},
+		{name: "mismatch - wrong order", pattern: "spd", text: "docker ps", wantOk: false},
+		{name: "mismatch - smart case", pattern: "Docker", text: "docker ps", wantOk: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, ok := fuzzyMatch(tt.pattern, tt.text)
+			if ok != tt.wantOk {
+				t.Errorf("Expected match %v, but got %v", tt.wantOk, ok)
+			}
+		})
+	}
+}
+
+func TestFuzzyMatch_PrefersConsecutiveCharacters(t *testing.T) {
+	consecutive, _ := fuzzyMatch("ps", "docker ps")
+	scattered, _ := fuzzyMatch("ps", "print status")
+
+	if consecutive <= scattered {
+		t.Errorf("Expected consecutive match score %d to be higher than %d", consecutive, scattered)
+	}
+}
+
+func TestFilterItems(t *testing.T) {
+	items := []FinderItem{
+		{Text: "[list files]: ls -la", Tags: []string{"files"}},
+		{Text: "[ping google]: ping 8.8.8.8", Tags: []string{"network", "google"}},
+		{Text: "[show processes]: ps aux", Tags: []string{"process"}},
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		want  []int
+	}{
+		{name: "empty query keeps order", query: "", want: []int{0, 1, 2}},
+		{name: "fuzzy term", query: "ping", want: []int{1}},
+		{name: "tag term", query: "#net", want: []int{1}},
+		{name: "tag and fuzzy term", query: "#process aux", want: []int{2}},
+		{name: "no match", query: "#files ping", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := filterItems(items, tt.query)
+			if diff := deep.Equal(tt.want, got); diff != nil {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestFinder_MultiSelect(t *testing.T) {
+	items := []FinderItem{{Text: "a"}, {Text: "b"}, {Text: "c"}}
+	f := newFinder(items, "")
+
+	// Mark "c" then "a", picked items are returned in list order
+	f.move(-1)
+	f.toggle()
+	f.toggle()
+	f.accept()
+
+	if diff := deep.Equal([]int{0, 2}, f.picked); diff != nil {
+		t.Fatal(diff)
+	}
+}
+
+func TestFinder_AcceptsCurrentItemWithoutMarks(t *testing.T) {
+	items := []FinderItem{{Text: "a"}, {Text: "b"}, {Text: "c"}}
+	f := newFinder(items, "")
+
+	f.move(1)
+	f.accept()
+
+	if diff := deep.Equal([]int{1}, f.picked); diff != nil {
+		t.Fatal(diff)
+	}
+
+	// Nothing is picked when nothing matches
+	f.setQuery("zzz")
+	f.accept()
+	if f.picked != nil {
+		t.Errorf("Expected nothing picked, got %v", f.picked)
+	}
+}
+
+func TestFinder_VisibleLines(t *testing.T) {
+	items := []FinderItem{{Text: "a"}, {Text: "b"}, {Text: "c"}}
+	f := newFinder(items, "")
+	f.toggle()
+	f.move(1)
+
+	want := []string{" *a", "  b", "> c"}
+	if diff := deep.Equal(want, f.visibleLines(3)); diff != nil {
+		t.Fatal(diff)
+	}
+
+	// The cursor stays visible when the list is taller than the view
+	want = []string{"  b", "> c"}
+	if diff := deep.Equal(want, f.visibleLines(2)); diff != nil {
+		t.Fatal(diff)
+	}
+}
@@ -1,10 +1,12 @@
 package dialog
 
 import (
+	"fmt"
 	"regexp"
 	"strings"
 
 	"github.com/awesome-gocui/gocui"
+	"github.com/knqyf263/pet/snippet"
 )
 
 var (
@@ -14,6 +16,13 @@ var (
 	CurrentCommand string
 	//FinalCommand is the command after assigning to variables
 	FinalCommand string
+	//FilledParams are the values assigned to the params of FinalCommand
+	FilledParams map[string]string
+
+	// paramSpecs declares how the params of the current command are validated
+	paramSpecs map[string]snippet.ParamSpec
+	// paramTitles keeps the view titles to restore them once a value is valid
+	paramTitles = map[string]string{}
 
 	// This matches most encountered patterns
 	// Skips match if there is a whitespace at the end ex. <param='my >
@@ -84,14 +93,115 @@ func SearchForParams(command string) [][2]string {
 	return ordered_params
 }
 
+// FillParams fills in the parameters of a command with the given values without the dialog.
+// Parameters without a given value take their default value if useDefaults is set,
+// the first option being the default of parameters with multiple default values.
+// Parameters declared as optional in specs may be left empty.
+// It returns the names of the parameters left without a value,
+// or an error if a value is not valid according to specs.
+func FillParams(command string, params [][2]string, values map[string]string, useDefaults bool, specs map[string]snippet.ParamSpec) (string, []string, error) {
+	filledInParams := map[string]string{}
+	var missing []string
+	for _, pair := range params {
+		parameterKey, parameterValue := pair[0], pair[1]
+		spec, declared := specs[parameterKey]
+
+		if value, ok := values[parameterKey]; ok {
+			filledInParams[parameterKey] = value
+		} else if defaultValue := firstDefaultValue(parameterValue); useDefaults && (defaultValue != "" || declared && !spec.Required) {
+			filledInParams[parameterKey] = defaultValue
+		} else {
+			missing = append(missing, parameterKey)
+		}
+	}
+
+	if len(missing) > 0 {
+		return command, missing, nil
+	}
+	if err := validateParams(filledInParams, specs); err != nil {
+		return command, nil, err
+	}
+	return insertParams(command, filledInParams), nil, nil
+}
+
+// validateParams returns an error for the first value which is not valid according to specs
+func validateParams(filledInParams map[string]string, specs map[string]snippet.ParamSpec) error {
+	for param, value := range filledInParams {
+		if err := validateParam(param, value, specs); err != nil {
+			return fmt.Errorf("invalid value for parameter %s: %v", param, err)
+		}
+	}
+	return nil
+}
+
+func validateParam(param, value string, specs map[string]snippet.ParamSpec) error {
+	spec, ok := specs[param]
+	if !ok {
+		return nil
+	}
+	return spec.Validate(value)
+}
+
+// firstDefaultValue returns the first of multiple default values, or the value itself
+func firstDefaultValue(value string) string {
+	return DefaultValues(value)[0]
+}
+
+// DefaultValues returns the default values of a param,
+// which is the value itself unless multiple default values are given as |_value_|
+func DefaultValues(value string) []string {
+	r := regexp.MustCompile(parameterMultipleValueRegex)
+	matches := r.FindAllStringSubmatch(value, -1)
+	if len(matches) == 0 {
+		return []string{value}
+	}
+
+	values := []string{}
+	for _, p := range matches {
+		// Remove the separators
+		values = append(values, p[1][2:len(p[1])-2])
+	}
+	return values
+}
+
+// MultipleDefaultValues returns the values written as multiple default values
+func MultipleDefaultValues(values []string) string {
+	res := ""
+	for _, v := range values {
+		res += "|_" + v + "_|"
+	}
+	return res
+}
+
 func evaluateParams(g *gocui.Gui, _ *gocui.View) error {
 	paramsFilled := map[string]string{}
-	for _, v := range views {
+	invalid := -1
+	for i, v := range views {
 		view, _ := g.View(v)
 		res := view.Buffer()
 		res = strings.Replace(res, "\n", "", -1)
 		paramsFilled[v] = strings.TrimSpace(res)
+
+		// Keep the dialog open and point at the values which are not valid
+		if _, ok := paramTitles[v]; !ok {
+			paramTitles[v] = view.Title
+		}
+		view.Title = paramTitles[v]
+		if err := validateParam(v, paramsFilled[v], paramSpecs); err != nil {
+			view.Title = fmt.Sprintf("%s => %v", paramTitles[v], err)
+			if invalid < 0 {
+				invalid = i
+			}
+		}
+	}
+
+	if invalid >= 0 {
+		curView = invalid
+		_, err := g.SetCurrentView(views[invalid])
+		return err
 	}
+
 	FinalCommand = insertParams(CurrentCommand, paramsFilled)
+	FilledParams = paramsFilled
 	return gocui.ErrQuit
 }
@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/go-test/deep"
+	"github.com/knqyf263/pet/snippet"
 )
 
 func TestSearchForParams(t *testing.T) {
@@ -284,3 +285,133 @@ func TestInsertParams_EqualsInDefaultValueIgnored(t *testing.T) {
 		t.Fatalf("got %s, want %s", got, want)
 	}
 }
+
+func TestFillParams(t *testing.T) {
+	command := "echo <a=1> <b> <c=|_x_||_y_|>"
+	params := SearchForParams(command)
+
+	tests := []struct {
+		name        string
+		values      map[string]string
+		useDefaults bool
+		want        string
+		wantMissing []string
+	}{
+		{
+			name:        "all values given",
+			values:      map[string]string{"a": "one", "b": "two", "c": "three"},
+			want:        "echo one two three",
+			wantMissing: nil,
+		},
+		{
+			name:        "defaults are not used without useDefaults",
+			values:      map[string]string{"b": "two"},
+			want:        command,
+			wantMissing: []string{"a", "c"},
+		},
+		{
+			name:        "defaults are used with useDefaults",
+			values:      map[string]string{"b": "two"},
+			useDefaults: true,
+			want:        "echo 1 two x",
+			wantMissing: nil,
+		},
+		{
+			name:        "parameter without default is missing",
+			values:      map[string]string{},
+			useDefaults: true,
+			want:        command,
+			wantMissing: []string{"b"},
+		},
+		{
+			name:        "empty value can be given explicitly",
+			values:      map[string]string{"b": ""},
+			useDefaults: true,
+			want:        "echo 1  x",
+			wantMissing: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, missing, err := FillParams(command, params, tt.values, tt.useDefaults, nil)
+			if err != nil {
+				t.Fatalf("Expected no error, but got %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected command %q, but got %q", tt.want, got)
+			}
+			if diff := deep.Equal(tt.wantMissing, missing); diff != nil {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestFillParams_WithSpecs(t *testing.T) {
+	command := "nc -l <port=8080> <verbose>"
+	params := SearchForParams(command)
+	specs := map[string]snippet.ParamSpec{
+		"port":    {Type: snippet.ParamTypeInt, Required: true},
+		"verbose": {Type: snippet.ParamTypeBool},
+	}
+
+	t.Run("success - optional param left empty", func(t *testing.T) {
+		got, missing, err := FillParams(command, params, map[string]string{}, true, specs)
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		if missing != nil {
+			t.Fatalf("Expected no missing params, but got %v", missing)
+		}
+		if got != "nc -l 8080 " {
+			t.Errorf("Expected command %q, but got %q", "nc -l 8080 ", got)
+		}
+	})
+
+	t.Run("error - invalid value", func(t *testing.T) {
+		_, _, err := FillParams(command, params, map[string]string{"port": "http"}, true, specs)
+		want := "invalid value for parameter port: must be an integer"
+		if err == nil || err.Error() != want {
+			t.Errorf("Expected error %q, but got %v", want, err)
+		}
+	})
+}
+
+func TestParamTitleAndEnumOptions(t *testing.T) {
+	t.Cleanup(func() {
+		paramSpecs = nil
+	})
+
+	paramSpecs = map[string]snippet.ParamSpec{
+		"env":  {Type: snippet.ParamTypeEnum, Values: []string{"dev", "staging", "prod"}, Description: "Target environment"},
+		"port": {Type: snippet.ParamTypeInt},
+	}
+
+	if got := paramTitle("env"); got != "env - Target environment" {
+		t.Errorf("Expected title with description, but got %q", got)
+	}
+	if got := paramTitle("port"); got != "port" {
+		t.Errorf("Expected plain title, but got %q", got)
+	}
+
+	if diff := deep.Equal([]string{"prod", "dev", "staging"}, enumOptions("env", "prod")); diff != nil {
+		t.Fatal(diff)
+	}
+	if got := enumOptions("port", "80"); got != nil {
+		t.Errorf("Expected no options for a non enum param, but got %v", got)
+	}
+}
+
+func TestDefaultValues(t *testing.T) {
+	if diff := deep.Equal([]string{"a b", "c"}, DefaultValues("|_a b_||_c_|")); diff != nil {
+		t.Fatal(diff)
+	}
+	if diff := deep.Equal([]string{"single"}, DefaultValues("single")); diff != nil {
+		t.Fatal(diff)
+	}
+
+	if got := MultipleDefaultValues([]string{"a b", "c"}); got != "|_a b_||_c_|" {
+		t.Errorf("Expected multiple default values, but got %q", got)
+	}
+}
@@ -4,8 +4,10 @@ import (
 	"fmt"
 	"log"
 	"regexp"
+	"slices"
 
 	"github.com/awesome-gocui/gocui"
+	"github.com/knqyf263/pet/snippet"
 )
 
 var (
@@ -49,6 +51,8 @@ func generateSingleParameterView(g *gocui.Gui, name string, defaultParam string,
 		return err
 	}
 
+	view.Title = paramTitle(name)
+
 	g.SetKeybinding(view.Name(), gocui.KeyCtrlK, gocui.ModNone, func(g *gocui.Gui, v *gocui.View) error {
 		v.Clear()
 		return nil
@@ -69,11 +73,11 @@ func generateMultipleParameterView(g *gocui.Gui, name string, defaultParams []st
 
 	fmt.Fprint(view, defaultParams[currentOpt])
 
-	viewTitle := name
+	viewTitle := paramTitle(name)
 	// Adjust view title to hint the user about the available
 	// options if there are more than one
 	if maxOpt > 1 {
-		viewTitle = name + " (UP/DOWN => Select default value)"
+		viewTitle += " (UP/DOWN => Select default value)"
 	}
 
 	view.Title = viewTitle
@@ -109,8 +113,39 @@ func generateMultipleParameterView(g *gocui.Gui, name string, defaultParams []st
 	return nil
 }
 
+// paramTitle returns the view title of a param, including its description if declared
+func paramTitle(name string) string {
+	if spec, ok := paramSpecs[name]; ok && spec.Description != "" {
+		return name + " - " + spec.Description
+	}
+	return name
+}
+
+// enumOptions returns the values of an enum param, starting with its default value
+func enumOptions(name string, defaultValue string) []string {
+	spec, ok := paramSpecs[name]
+	if !ok || spec.Type != snippet.ParamTypeEnum || len(spec.Values) == 0 {
+		return nil
+	}
+
+	options := []string{}
+	if defaultValue != "" {
+		options = append(options, defaultValue)
+	}
+	for _, v := range spec.Values {
+		if !slices.Contains(options, v) {
+			options = append(options, v)
+		}
+	}
+	return options
+}
+
 // GenerateParamsLayout generates CUI to receive params
-func GenerateParamsLayout(params [][2]string, command string) {
+// specs declares how the params are validated and described, it may be nil
+func GenerateParamsLayout(params [][2]string, command string, specs map[string]snippet.ParamSpec) {
+	paramSpecs = specs
+	paramTitles = map[string]string{}
+
 	g, err := gocui.NewGui(gocui.OutputNormal, false)
 	if err != nil {
 		log.Panicln(err)
@@ -140,7 +175,16 @@ func GenerateParamsLayout(params [][2]string, command string) {
 		r := regexp.MustCompile(parameterMultipleValueRegex)
 		matches := r.FindAllStringSubmatch(parameterValue, -1)
 
-		if len(matches) > 0 {
+		if options := enumOptions(parameterKey, parameterValue); len(matches) == 0 && options != nil {
+			// Cycle through the declared values of an enum
+			generateMultipleParameterView(
+				g, parameterKey, options, [4]int{
+					leftX,
+					(maxY / 4) + (idx+1)*layoutStep,
+					rightX,
+					(maxY / 4) + 2 + (idx+1)*layoutStep},
+				true)
+		} else if len(matches) > 0 {
 			// Extract the default values and generate multiple params view
 			parameters := []string{}
 			for _, p := range matches {
@@ -0,0 +1,205 @@
+package snippet
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/knqyf263/pet/config"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/term"
+)
+
+const (
+	encryptedHeader = "-----BEGIN PET ENCRYPTED SNIPPETS-----"
+	encryptedFooter = "-----END PET ENCRYPTED SNIPPETS-----"
+
+	// encryptedVersion is the first byte of the encrypted payload
+	encryptedVersion = 1
+
+	passphraseEnvVariable = "PET_PASSPHRASE"
+
+	saltSize  = 16
+	keySize   = 32
+	lineWidth = 64
+)
+
+// kdfIterations is the number of PBKDF2 iterations for new payloads,
+// the iterations of existing payloads are read from them
+var kdfIterations uint32 = 600000
+
+var (
+	passphraseMu     sync.Mutex
+	cachedPassphrase string
+
+	keysMu sync.Mutex
+	keys   = map[string][]byte{} // by passphrase and salt
+)
+
+// IsEncrypted reports whether data is encrypted by pet
+func IsEncrypted(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(data), []byte(encryptedHeader))
+}
+
+// Encrypt encrypts data with AES-256-GCM and a key derived from the passphrase.
+// The result is text, so that it can be stored wherever snippet files can.
+func Encrypt(data []byte) ([]byte, error) {
+	passphrase, err := getPassphrase()
+	if err != nil {
+		return nil, err
+	}
+
+	// Each payload has a salt of its own, and so a key of its own
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	gcm, err := newGCM(passphrase, salt, kdfIterations)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	payload := []byte{encryptedVersion}
+	payload = binary.BigEndian.AppendUint32(payload, kdfIterations)
+	payload = append(payload, salt...)
+	payload = append(payload, nonce...)
+	payload = gcm.Seal(payload, nonce, data, nil)
+
+	var buffer bytes.Buffer
+	buffer.WriteString(encryptedHeader + "\n")
+	encoded := base64.StdEncoding.EncodeToString(payload)
+	for len(encoded) > lineWidth {
+		buffer.WriteString(encoded[:lineWidth] + "\n")
+		encoded = encoded[lineWidth:]
+	}
+	buffer.WriteString(encoded + "\n")
+	buffer.WriteString(encryptedFooter + "\n")
+	return buffer.Bytes(), nil
+}
+
+// Decrypt decrypts data encrypted by Encrypt. Data which is not encrypted is returned as is.
+func Decrypt(data []byte) ([]byte, error) {
+	if !IsEncrypted(data) {
+		return data, nil
+	}
+
+	body := strings.TrimSpace(string(data))
+	body = strings.TrimPrefix(body, encryptedHeader)
+	body, ok := strings.CutSuffix(body, encryptedFooter)
+	if !ok {
+		return nil, errors.New("encrypted snippets are truncated")
+	}
+	payload, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(body), ""))
+	if err != nil {
+		return nil, fmt.Errorf("encrypted snippets are corrupted. %v", err)
+	}
+
+	const headerSize = 1 + 4 + saltSize
+	if len(payload) < headerSize || payload[0] != encryptedVersion {
+		return nil, errors.New("encrypted snippets have an unknown format, upgrade pet")
+	}
+	iterations := binary.BigEndian.Uint32(payload[1:5])
+	salt := payload[5:headerSize]
+
+	passphrase, err := getPassphrase()
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(passphrase, salt, iterations)
+	if err != nil {
+		return nil, err
+	}
+
+	payload = payload[headerSize:]
+	if len(payload) < gcm.NonceSize() {
+		return nil, errors.New("encrypted snippets are truncated")
+	}
+	plain, err := gcm.Open(nil, payload[:gcm.NonceSize()], payload[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, errors.New("failed to decrypt snippets, wrong passphrase?")
+	}
+	return plain, nil
+}
+
+// getPassphrase returns the passphrase from $PET_PASSPHRASE, the passphrase_command
+// or, failing both, asks for it on the terminal
+func getPassphrase() (string, error) {
+	if passphrase := os.Getenv(passphraseEnvVariable); passphrase != "" {
+		return passphrase, nil
+	}
+
+	passphraseMu.Lock()
+	defer passphraseMu.Unlock()
+	if cachedPassphrase != "" {
+		return cachedPassphrase, nil
+	}
+
+	var passphrase string
+	if command := config.Conf.Encryption.PassphraseCommand; command != "" {
+		out, err := shellCommand(command).Output()
+		if err != nil {
+			return "", fmt.Errorf("failed to run passphrase_command. %v", err)
+		}
+		passphrase = strings.TrimRight(string(out), "\r\n")
+	} else if term.IsTerminal(int(os.Stdin.Fd())) {
+		fmt.Fprint(os.Stderr, "Passphrase: ")
+		input, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", fmt.Errorf("failed to read passphrase. %v", err)
+		}
+		passphrase = string(input)
+	}
+
+	if passphrase == "" {
+		return "", fmt.Errorf(`passphrase is empty.
+Export $%v or write passphrase_command in [Encryption] in config file (pet configure).`, passphraseEnvVariable)
+	}
+	cachedPassphrase = passphrase
+	return passphrase, nil
+}
+
+// shellCommand returns a command line run by the shell
+func shellCommand(command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/C", command)
+	}
+	return exec.Command("sh", "-c", command)
+}
+
+// newGCM returns AES-256-GCM keyed with the key derived from the passphrase and salt
+func newGCM(passphrase string, salt []byte, iterations uint32) (cipher.AEAD, error) {
+	id := passphrase + "\x00" + string(salt) + "\x00" + fmt.Sprint(iterations)
+
+	keysMu.Lock()
+	key, ok := keys[id]
+	keysMu.Unlock()
+	if !ok {
+		key = pbkdf2.Key([]byte(passphrase), salt, int(iterations), keySize, sha256.New)
+		keysMu.Lock()
+		keys[id] = key
+		keysMu.Unlock()
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,134 @@
+package snippet
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/knqyf263/pet/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupEncryption(t *testing.T, passphrase string) {
+	t.Setenv(passphraseEnvVariable, passphrase)
+
+	orig := kdfIterations
+	t.Cleanup(func() { kdfIterations = orig })
+	kdfIterations = 1000
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	setupEncryption(t, "correct horse")
+
+	plain := []byte("[[Snippets]]\n  command = \"ssh db.internal\"\n")
+	encrypted, err := Encrypt(plain)
+	require.NoError(t, err)
+	assert.True(t, IsEncrypted(encrypted))
+	assert.NotContains(t, string(encrypted), "db.internal")
+	for _, line := range strings.Split(strings.TrimSpace(string(encrypted)), "\n") {
+		assert.LessOrEqual(t, len(line), 64)
+	}
+
+	decrypted, err := Decrypt(encrypted)
+	require.NoError(t, err)
+	assert.Equal(t, plain, decrypted)
+
+	// The same content never encrypts the same
+	again, err := Encrypt(plain)
+	require.NoError(t, err)
+	assert.NotEqual(t, encrypted, again)
+
+	// Plain text is returned as is
+	decrypted, err = Decrypt(plain)
+	require.NoError(t, err)
+	assert.Equal(t, plain, decrypted)
+
+	t.Setenv(passphraseEnvVariable, "wrong horse")
+	_, err = Decrypt(encrypted)
+	assert.ErrorContains(t, err, "wrong passphrase")
+}
+
+func TestDecryptCorrupted(t *testing.T) {
+	setupEncryption(t, "correct horse")
+
+	encrypted, err := Encrypt([]byte("ls"))
+	require.NoError(t, err)
+
+	_, err = Decrypt([]byte(strings.TrimSuffix(string(encrypted), encryptedFooter+"\n")))
+	assert.ErrorContains(t, err, "truncated")
+
+	_, err = Decrypt([]byte(encryptedHeader + "\n!!!\n" + encryptedFooter + "\n"))
+	assert.ErrorContains(t, err, "corrupted")
+
+	lines := strings.Split(string(encrypted), "\n")
+	lines[1] = strings.Repeat("A", len(lines[1]))
+	_, err = Decrypt([]byte(strings.Join(lines, "\n")))
+	assert.Error(t, err)
+}
+
+func TestPassphraseCommand(t *testing.T) {
+	setupEncryption(t, "")
+	orig := config.Conf
+	defer func() { config.Conf = orig }()
+	defer func() { cachedPassphrase = "" }()
+
+	config.Conf.Encryption.PassphraseCommand = "echo from command"
+	encrypted, err := Encrypt([]byte("ls"))
+	require.NoError(t, err)
+
+	t.Setenv(passphraseEnvVariable, "from command")
+	decrypted, err := Decrypt(encrypted)
+	require.NoError(t, err)
+	assert.Equal(t, "ls", string(decrypted))
+}
+
+func TestEncryptUsesFreshSalt(t *testing.T) {
+	setupEncryption(t, "correct horse")
+
+	salts := map[string]bool{}
+	for i := 0; i < 3; i++ {
+		encrypted, err := Encrypt([]byte("ls"))
+		require.NoError(t, err)
+		payload, err := base64.StdEncoding.DecodeString(strings.Join(strings.Split(strings.TrimSpace(string(encrypted)), "\n")[1:2], ""))
+		require.NoError(t, err)
+		salts[string(payload[5:5+saltSize])] = true
+	}
+	assert.Len(t, salts, 3)
+}
+
+func TestLoadAndSaveEncryptedFiles(t *testing.T) {
+	setupEncryption(t, "correct horse")
+	orig := config.Conf
+	defer func() { config.Conf = orig }()
+
+	snippetFile := filepath.Join(t.TempDir(), "snippet.toml")
+	require.NoError(t, os.WriteFile(snippetFile, []byte("[[Snippets]]\n  id = \"1\"\n  command = \"ssh db.internal\"\n"), 0600))
+	config.Conf = config.Config{}
+	config.Conf.General.SnippetFile = snippetFile
+	config.Conf.Encryption.Files = true
+
+	// Plain text files are read as before and encrypted when saved
+	var snippets Snippets
+	require.NoError(t, snippets.Load(false))
+	require.Len(t, snippets.Snippets, 1)
+	require.NoError(t, snippets.Save())
+
+	data, err := os.ReadFile(snippetFile)
+	require.NoError(t, err)
+	assert.True(t, IsEncrypted(data))
+	assert.NotContains(t, string(data), "db.internal")
+
+	var loaded Snippets
+	require.NoError(t, loaded.Load(false))
+	assert.Equal(t, "ssh db.internal", loaded.Snippets[0].Command)
+
+	// With files encryption turned off, saving decrypts them again
+	config.Conf.Encryption.Files = false
+	require.NoError(t, loaded.Save())
+	data, err = os.ReadFile(snippetFile)
+	require.NoError(t, err)
+	assert.False(t, IsEncrypted(data))
+}
@@ -0,0 +1,171 @@
+package snippet
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/knqyf263/pet/config"
+)
+
+// backupSuffix is appended to the name of a snippet file to name the backup of its previous version
+const backupSuffix = ".bak"
+
+// ReadFile reads a snippet file, decrypting it if it is encrypted
+func ReadFile(name string) ([]byte, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return Decrypt(data)
+}
+
+// hasContent reports whether a snippet file already holds data,
+// encrypted or not as encryption of files is configured
+func hasContent(name string, data []byte) bool {
+	raw, err := os.ReadFile(name)
+	if err != nil || IsEncrypted(raw) != config.Conf.Encryption.Files {
+		return false
+	}
+	current, err := Decrypt(raw)
+	return err == nil && bytes.Equal(current, data)
+}
+
+// WriteFile replaces a snippet file, encrypted if encryption of files is enabled.
+// The previous version is kept as the .bak file next to it. Writing the content the file
+// already holds changes nothing, so that the .bak file stays the version before the last change.
+func WriteFile(name string, data []byte, perm os.FileMode) error {
+	return writeFile(name, data, perm, true)
+}
+
+// WriteCopy replaces a copy of snippets kept by pet, such as the snippets of the last sync,
+// like WriteFile but without a backup
+func WriteCopy(name string, data []byte, perm os.FileMode) error {
+	return writeFile(name, data, perm, false)
+}
+
+// writeFile writes data to a temporary file in the same directory and renames it
+// over the file, so that a crash or a failed write never leaves a partial file.
+// An existing file keeps its mode, perm is the mode of a new file before the umask.
+func writeFile(name string, data []byte, perm os.FileMode, backup bool) error {
+	if backup && hasContent(name, data) {
+		return nil
+	}
+	if config.Conf.Encryption.Files {
+		var err error
+		if data, err = Encrypt(data); err != nil {
+			return err
+		}
+	}
+
+	// A symlinked snippet file stays a symlink, its target is replaced
+	if target, err := filepath.EvalSymlinks(name); err == nil {
+		name = target
+	}
+
+	fi, err := os.Stat(name)
+	exists := err == nil
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	tmp, err := createTemp(name, perm)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // does nothing once renamed
+
+	if exists {
+		if err := tmp.Chmod(fi.Mode().Perm()); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if exists && backup {
+		if err := backupFile(name); err != nil {
+			return fmt.Errorf("failed to back up %s. %v", name, err)
+		}
+	}
+	if err := os.Rename(tmp.Name(), name); err != nil {
+		return err
+	}
+	syncDir(filepath.Dir(name))
+	return nil
+}
+
+// createTemp creates a hidden temporary file next to name, with perm subject to the umask
+func createTemp(name string, perm os.FileMode) (*os.File, error) {
+	dir, base := filepath.Split(name)
+	for i := 0; ; i++ {
+		tmp := filepath.Join(dir, fmt.Sprintf(".%s.%d-%d.tmp", base, os.Getpid(), i))
+		f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+		if !os.IsExist(err) {
+			return f, err
+		}
+	}
+}
+
+// backupFile replaces the .bak file of name with the current version of name
+func backupFile(name string) error {
+	bak := name + backupSuffix
+	tmp := bak + ".tmp"
+	os.Remove(tmp)
+
+	// A hard link keeps the current version without copying it,
+	// the file itself is replaced by a new one right after
+	if err := os.Link(name, tmp); err != nil {
+		if err := copyFile(name, tmp); err != nil {
+			os.Remove(tmp)
+			return err
+		}
+	}
+	return os.Rename(tmp, bak)
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	fi, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fi.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// syncDir flushes the rename of a file in dir to disk where the platform allows it
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
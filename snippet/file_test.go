@@ -0,0 +1,103 @@
+package snippet
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/knqyf263/pet/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "snippet.toml")
+
+	require.NoError(t, WriteFile(file, []byte("first"), 0666))
+	assert.Equal(t, "first", readTestFile(t, file))
+	assert.NoFileExists(t, file+backupSuffix)
+
+	require.NoError(t, WriteFile(file, []byte("second"), 0666))
+	require.NoError(t, WriteFile(file, []byte("third"), 0666))
+	assert.Equal(t, "third", readTestFile(t, file))
+	assert.Equal(t, "second", readTestFile(t, file+backupSuffix))
+
+	// Writing the same content again keeps the backup of the last change
+	require.NoError(t, WriteFile(file, []byte("third"), 0666))
+	assert.Equal(t, "third", readTestFile(t, file))
+	assert.Equal(t, "second", readTestFile(t, file+backupSuffix))
+
+	// No temporary file is left behind
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	assert.Equal(t, []string{"snippet.toml", "snippet.toml.bak"}, names)
+}
+
+func TestWriteFileKeepsMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes are not supported on Windows")
+	}
+	file := filepath.Join(t.TempDir(), "snippet.toml")
+	require.NoError(t, os.WriteFile(file, []byte("first"), 0600))
+	require.NoError(t, os.Chmod(file, 0640))
+
+	require.NoError(t, WriteFile(file, []byte("second"), 0666))
+	fi, err := os.Stat(file)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), fi.Mode().Perm())
+}
+
+func TestWriteFileThroughSymlink(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need privileges on Windows")
+	}
+	dir := t.TempDir()
+	target := filepath.Join(dir, "dotfiles", "snippet.toml")
+	link := filepath.Join(dir, "snippet.toml")
+	require.NoError(t, os.MkdirAll(filepath.Dir(target), 0700))
+	require.NoError(t, os.WriteFile(target, []byte("first"), 0600))
+	require.NoError(t, os.Symlink(target, link))
+
+	require.NoError(t, WriteFile(link, []byte("second"), 0666))
+	fi, err := os.Lstat(link)
+	require.NoError(t, err)
+	assert.Equal(t, os.ModeSymlink, fi.Mode()&os.ModeSymlink)
+	assert.Equal(t, "second", readTestFile(t, target))
+	assert.Equal(t, "first", readTestFile(t, target+backupSuffix))
+}
+
+func TestWriteFileFailureKeepsFile(t *testing.T) {
+	orig := config.Conf
+	defer func() { config.Conf = orig }()
+	setupEncryption(t, "")
+	config.Conf = config.Config{}
+
+	file := filepath.Join(t.TempDir(), "snippet.toml")
+	require.NoError(t, WriteFile(file, []byte("first"), 0666))
+
+	// Encrypting fails without a passphrase
+	config.Conf.Encryption.Files = true
+	config.Conf.Encryption.PassphraseCommand = "exit 1"
+	assert.Error(t, WriteFile(file, []byte("second"), 0666))
+	assert.Equal(t, "first", readTestFile(t, file))
+}
+
+func TestWriteCopy(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "pet-snippet.toml")
+	require.NoError(t, WriteCopy(file, []byte("first"), 0600))
+	require.NoError(t, WriteCopy(file, []byte("second"), 0600))
+	assert.Equal(t, "second", readTestFile(t, file))
+	assert.NoFileExists(t, file+backupSuffix)
+}
+
+func readTestFile(t *testing.T, name string) string {
+	data, err := os.ReadFile(name)
+	require.NoError(t, err)
+	return string(data)
+}
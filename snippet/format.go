@@ -0,0 +1,298 @@
+package snippet
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Format reads and writes the snippets of snippet files in one file format
+type Format interface {
+	// Decode parses the snippets of a file
+	Decode(data []byte) ([]SnippetInfo, error)
+	// Encode returns the content of a file holding the snippets, nothing if there are none
+	Encode(snippets []SnippetInfo) ([]byte, error)
+}
+
+// formats are the formats of snippet files by name
+var formats = map[string]Format{
+	"toml":     tomlFormat{},
+	"yaml":     yamlFormat{},
+	"json":     jsonFormat{},
+	"markdown": markdownFormat{},
+}
+
+// formatExtensions maps the extensions of snippet files to the name of their format
+var formatExtensions = map[string]string{
+	".toml": "toml",
+	".yaml": "yaml",
+	".yml":  "yaml",
+	".json": "json",
+	".md":   "markdown",
+}
+
+var snippetFileRegEx = regexp.MustCompile(`^.+\.(toml|yaml|yml|json|md)$`)
+
+// IsSnippetFile reports whether a file in a snippet directory is a snippet file by its name
+func IsSnippetFile(name string) bool {
+	return snippetFileRegEx.MatchString(name)
+}
+
+// isSnippetDocument reports whether a YAML or JSON file holds a snippets document,
+// or nothing yet, rather than the configuration of another tool which shares its extension.
+// Files of other formats and files which cannot be read count as snippet files, so that
+// loading them reports what is wrong.
+func isSnippetDocument(file string) bool {
+	var unmarshal func([]byte, any) error
+	switch FormatOf(file).(type) {
+	case yamlFormat:
+		unmarshal = yaml.Unmarshal
+	case jsonFormat:
+		unmarshal = json.Unmarshal
+	default:
+		return true
+	}
+
+	data, err := ReadFile(file)
+	if err != nil || len(bytes.TrimSpace(data)) == 0 {
+		return true
+	}
+	var doc map[string]any
+	if err := unmarshal(data, &doc); err != nil {
+		return false
+	}
+	_, ok := doc["snippets"]
+	return ok || len(doc) == 0
+}
+
+// FormatOf returns the format of a snippet file by its extension.
+// Files without a known extension, such as the main snippet file, are TOML.
+func FormatOf(file string) Format {
+	name, ok := formatExtensions[strings.ToLower(filepath.Ext(file))]
+	switch {
+	case !ok:
+		return tomlFormat{}
+	case name == "markdown":
+		return markdownFormat{file: filepath.Base(file)}
+	}
+	return formats[name]
+}
+
+// IsReadOnly reports whether pet only reads a snippet file, leaving changes to its author
+func IsReadOnly(file string) bool {
+	_, ok := FormatOf(file).(markdownFormat)
+	return ok
+}
+
+// FormatByName returns a format by its name, or the extension of its files without the dot,
+// and the extension of its files
+func FormatByName(name string) (format Format, ext string, ok bool) {
+	ext = "." + strings.ToLower(name)
+	if name, ok := formatExtensions[ext]; ok {
+		return formats[name], ext, true
+	}
+	return nil, "", false
+}
+
+// ConvertFile rewrites a snippet file in another format, next to it with the extension
+// of the format. The original file is kept as its .bak file.
+func ConvertFile(file string, name string) (string, error) {
+	format, ext, ok := FormatByName(name)
+	if _, readOnly := format.(markdownFormat); !ok || readOnly {
+		return "", fmt.Errorf("unknown format: %s, use toml, yaml or json", name)
+	}
+
+	unlock, err := Lock()
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	converted := strings.TrimSuffix(file, filepath.Ext(file)) + ext
+	if converted == file {
+		return "", fmt.Errorf("%s is already a %s file", file, name)
+	}
+	if _, err := os.Stat(converted); err == nil {
+		return "", fmt.Errorf("%s already exists", converted)
+	}
+
+	data, err := ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("failed to load snippet file. %v", err)
+	}
+	snippets, err := FormatOf(file).Decode(data)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse snippet file. %v", err)
+	}
+	// Undoing the conversion brings the file back from the journal
+	if err := RecordVersion(file, data, ReasonOriginal); err != nil {
+		return "", err
+	}
+	if data, err = format.Encode(snippets); err != nil {
+		return "", fmt.Errorf("failed to encode snippets. %v", err)
+	}
+
+	if err := WriteFile(converted, data, 0666); err != nil {
+		return "", fmt.Errorf("failed to save snippet file. %v", err)
+	}
+	if err := RecordVersion(converted, data, ReasonConvert); err != nil {
+		return "", err
+	}
+	if err := os.Rename(file, file+backupSuffix); err != nil {
+		return "", err
+	}
+	return converted, RecordDeletion(file, ReasonConvert)
+}
+
+type tomlFormat struct{}
+
+func (tomlFormat) Decode(data []byte) ([]SnippetInfo, error) {
+	var snippets Snippets
+	if err := toml.Unmarshal(data, &snippets); err != nil {
+		return nil, err
+	}
+	return snippets.Snippets, nil
+}
+
+func (tomlFormat) Encode(snippets []SnippetInfo) ([]byte, error) {
+	var buffer bytes.Buffer
+	if err := toml.NewEncoder(&buffer).Encode(Snippets{Snippets: snippets}); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// fileSnippets is the document of a YAML or JSON snippet file
+type fileSnippets struct {
+	Snippets []fileSnippet `yaml:"snippets" json:"snippets"`
+}
+
+// fileSnippet is a snippet in a YAML or JSON snippet file, where keys are lowercase
+// and times are left out unless set
+type fileSnippet struct {
+	ID          string               `yaml:"id,omitempty" json:"id,omitempty"`
+	Description string               `yaml:"description" json:"description"`
+	Command     string               `yaml:"command" json:"command"`
+	Tag         []string             `yaml:"tag,omitempty" json:"tag,omitempty"`
+	Output      string               `yaml:"output,omitempty" json:"output,omitempty"`
+	Params      map[string]ParamSpec `yaml:"params,omitempty" json:"params,omitempty"`
+	CreatedAt   *time.Time           `yaml:"created_at,omitempty" json:"created_at,omitempty"`
+	UpdatedAt   *time.Time           `yaml:"updated_at,omitempty" json:"updated_at,omitempty"`
+	LastUsedAt  *time.Time           `yaml:"last_used_at,omitempty" json:"last_used_at,omitempty"`
+}
+
+func toFileSnippets(snippets []SnippetInfo) fileSnippets {
+	timeOrNil := func(t time.Time) *time.Time {
+		if t.IsZero() {
+			return nil
+		}
+		return &t
+	}
+
+	var doc fileSnippets
+	for _, s := range snippets {
+		doc.Snippets = append(doc.Snippets, fileSnippet{
+			ID:          s.ID,
+			Description: s.Description,
+			Command:     s.Command,
+			Tag:         s.Tag,
+			Output:      s.Output,
+			Params:      s.Params,
+			CreatedAt:   timeOrNil(s.CreatedAt),
+			UpdatedAt:   timeOrNil(s.UpdatedAt),
+			LastUsedAt:  timeOrNil(s.LastUsedAt),
+		})
+	}
+	return doc
+}
+
+func (doc fileSnippets) snippetInfos() []SnippetInfo {
+	timeOrZero := func(t *time.Time) time.Time {
+		if t == nil {
+			return time.Time{}
+		}
+		return *t
+	}
+
+	var snippets []SnippetInfo
+	for _, s := range doc.Snippets {
+		snippets = append(snippets, SnippetInfo{
+			ID:          s.ID,
+			Description: s.Description,
+			Command:     s.Command,
+			Tag:         s.Tag,
+			Output:      s.Output,
+			Params:      s.Params,
+			CreatedAt:   timeOrZero(s.CreatedAt),
+			UpdatedAt:   timeOrZero(s.UpdatedAt),
+			LastUsedAt:  timeOrZero(s.LastUsedAt),
+		})
+	}
+	return snippets
+}
+
+type yamlFormat struct{}
+
+func (yamlFormat) Decode(data []byte) ([]SnippetInfo, error) {
+	var doc fileSnippets
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return doc.snippetInfos(), nil
+}
+
+func (yamlFormat) Encode(snippets []SnippetInfo) ([]byte, error) {
+	if len(snippets) == 0 {
+		return nil, nil
+	}
+
+	var buffer bytes.Buffer
+	encoder := yaml.NewEncoder(&buffer)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(toFileSnippets(snippets)); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+type jsonFormat struct{}
+
+func (jsonFormat) Decode(data []byte) ([]SnippetInfo, error) {
+	// An emptied snippet file holds nothing
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, nil
+	}
+
+	var doc fileSnippets
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return doc.snippetInfos(), nil
+}
+
+func (jsonFormat) Encode(snippets []SnippetInfo) ([]byte, error) {
+	if len(snippets) == 0 {
+		return nil, nil
+	}
+
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetIndent("", "  ")
+	// Commands are not HTML, keep their < > & readable
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(toFileSnippets(snippets)); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
@@ -0,0 +1,162 @@
+package snippet
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/knqyf263/pet/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatsRoundTrip(t *testing.T) {
+	created := time.Date(2024, 5, 1, 13, 14, 15, 0, time.UTC)
+	snippets := []SnippetInfo{
+		{
+			ID:          "a",
+			Description: "find large files",
+			Command:     "find <dir=.> -size +<size=100M>\n  | sort > /tmp/out && cat /tmp/out",
+			Tag:         []string{"files", "disk"},
+			Output:      "./big.iso",
+			Params:      map[string]ParamSpec{"size": {Type: ParamTypeString, Required: true}},
+			CreatedAt:   created,
+			UpdatedAt:   created,
+		},
+		{ID: "b", Description: "list", Command: "ls"},
+	}
+
+	for _, name := range []string{"toml", "yaml", "yml", "json"} {
+		t.Run(name, func(t *testing.T) {
+			format, ext, ok := FormatByName(name)
+			require.True(t, ok)
+			assert.Equal(t, "."+name, ext)
+
+			data, err := format.Encode(snippets)
+			require.NoError(t, err)
+			decoded, err := format.Decode(data)
+			require.NoError(t, err)
+			require.Len(t, decoded, 2)
+			assert.True(t, sameSnippet(snippets[0], decoded[0]), "%+v", decoded[0])
+			assert.True(t, sameSnippet(snippets[1], decoded[1]), "%+v", decoded[1])
+			assert.True(t, decoded[0].CreatedAt.Equal(created))
+
+			// A file without snippets is empty
+			data, err = format.Encode(nil)
+			require.NoError(t, err)
+			assert.Empty(t, string(data))
+			decoded, err = format.Decode(data)
+			require.NoError(t, err)
+			assert.Empty(t, decoded)
+		})
+	}
+
+	_, _, ok := FormatByName("xml")
+	assert.False(t, ok)
+}
+
+func TestYAMLFormat(t *testing.T) {
+	data, err := yamlFormat{}.Encode([]SnippetInfo{{ID: "a", Description: "loop", Command: "for f in *; do\n  echo $f\ndone"}})
+	require.NoError(t, err)
+	assert.Equal(t, `snippets:
+  - id: a
+    description: loop
+    command: |-
+      for f in *; do
+        echo $f
+      done
+`, string(data))
+}
+
+func TestJSONFormat(t *testing.T) {
+	data, err := jsonFormat{}.Encode([]SnippetInfo{{ID: "a", Description: "redirect", Command: "ls > out && cat out"}})
+	require.NoError(t, err)
+	assert.Equal(t, `{
+  "snippets": [
+    {
+      "id": "a",
+      "description": "redirect",
+      "command": "ls > out && cat out"
+    }
+  ]
+}
+`, string(data))
+
+	_, err = jsonFormat{}.Decode([]byte("{"))
+	assert.Error(t, err)
+}
+
+func TestFormatOf(t *testing.T) {
+	assert.Equal(t, yamlFormat{}, FormatOf("snippets.YML"))
+	assert.Equal(t, jsonFormat{}, FormatOf("/path/snippets.json"))
+	assert.Equal(t, tomlFormat{}, FormatOf("snippet.toml"))
+	// The main snippet file may have no extension
+	assert.Equal(t, tomlFormat{}, FormatOf("/path/to/snippet"))
+}
+
+func TestLoadAndSaveFormats(t *testing.T) {
+	file := setupJournal(t)
+	dir := filepath.Join(filepath.Dir(file), "snippets")
+	require.NoError(t, os.MkdirAll(dir, 0700))
+	config.Conf.General.SnippetDirs = []string{dir}
+
+	require.NoError(t, os.WriteFile(file, []byte("[[Snippets]]\n  id = \"a\"\n  command = \"ls\"\n"), 0600))
+	yamlFile := filepath.Join(dir, "k8s.yaml")
+	require.NoError(t, os.WriteFile(yamlFile, []byte("snippets:\n  - id: b\n    description: pods\n    command: kubectl get pods\n    tag: [k8s]\n"), 0600))
+	jsonFile := filepath.Join(dir, "git.json")
+	require.NoError(t, os.WriteFile(jsonFile, []byte(`{"snippets": [{"description": "status", "command": "git status"}]}`), 0600))
+
+	var snippets Snippets
+	require.NoError(t, snippets.Load(true))
+	require.Len(t, snippets.Snippets, 3)
+
+	// The JSON snippet got an ID, written back as JSON
+	s, ok := snippets.FindByID("b")
+	require.True(t, ok)
+	assert.Equal(t, yamlFile, s.Filename)
+	assert.Equal(t, []string{"k8s"}, s.Tag)
+	assert.Contains(t, readTestFile(t, jsonFile), `"id": "`)
+
+	s.Command = "kubectl get pods -A"
+	require.True(t, snippets.Update(s))
+	require.NoError(t, snippets.Save())
+	assert.Contains(t, readTestFile(t, yamlFile), "command: kubectl get pods -A\n")
+	assert.Contains(t, readTestFile(t, file), `command = "ls"`)
+}
+
+func TestConvertFile(t *testing.T) {
+	file := setupJournal(t)
+	tomlFile := filepath.Join(filepath.Dir(file), "git.toml")
+	require.NoError(t, os.WriteFile(tomlFile, []byte("[[Snippets]]\n  id = \"a\"\n  Description = \"status\"\n  command = \"git status\"\n"), 0600))
+
+	newRun()
+	converted, err := ConvertFile(tomlFile, "yaml")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(filepath.Dir(file), "git.yaml"), converted)
+	assert.Equal(t, "snippets:\n  - id: a\n    description: status\n    command: git status\n", readTestFile(t, converted))
+	assert.NoFileExists(t, tomlFile)
+	assert.FileExists(t, tomlFile+backupSuffix)
+
+	newRun()
+	_, err = ConvertFile(converted, "yml")
+	assert.NoError(t, err)
+	ymlFile := filepath.Join(filepath.Dir(file), "git.yml")
+	_, err = ConvertFile(ymlFile, "yml")
+	assert.EqualError(t, err, ymlFile+" is already a yml file")
+	_, err = ConvertFile(tomlFile, "xml")
+	assert.EqualError(t, err, "unknown format: xml, use toml, yaml or json")
+
+	// Undoing the last conversion brings back the YAML file
+	restored, err := Undo()
+	require.NoError(t, err)
+	assert.Len(t, restored, 2)
+	assert.FileExists(t, converted)
+	assert.NoFileExists(t, ymlFile)
+
+	// and the first one the TOML file
+	_, err = Undo()
+	require.NoError(t, err)
+	assert.Contains(t, readTestFile(t, tomlFile), `command = "git status"`)
+	assert.NoFileExists(t, converted)
+}
@@ -0,0 +1,129 @@
+package snippet
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/knqyf263/pet/config"
+	"github.com/knqyf263/pet/path"
+	"github.com/pelletier/go-toml"
+)
+
+// defaultParamHistorySize is how many values are remembered per parameter by default
+const defaultParamHistorySize = 10
+
+// ParamHistory is the values previously entered for the parameters of snippets,
+// keyed by snippet ID and then by parameter name, most recent first.
+// It is kept in a local history file, apart from the snippet files, so it is never synced.
+type ParamHistory struct {
+	Params map[string]map[string][]string `toml:"params"`
+}
+
+// historyFile returns the path of the local parameter history file
+func historyFile() (path.AbsolutePath, error) {
+	return localFile(config.Conf.General.ParamHistoryFile, "history.toml")
+}
+
+// paramHistorySize returns how many values are remembered per parameter, 0 if disabled
+func paramHistorySize() int {
+	size := config.Conf.General.ParamHistorySize
+	if size == 0 {
+		return defaultParamHistorySize
+	} else if size < 0 {
+		return 0
+	}
+	return size
+}
+
+// LoadParamHistory loads the parameter history from the history file
+func LoadParamHistory() (ParamHistory, error) {
+	history := ParamHistory{Params: map[string]map[string][]string{}}
+
+	file, err := historyFile()
+	if err != nil {
+		return history, err
+	}
+
+	f, err := ReadFile(file.Get())
+	if os.IsNotExist(err) {
+		return history, nil
+	} else if err != nil {
+		return history, fmt.Errorf("failed to load history file. %v", err)
+	}
+
+	if err := toml.Unmarshal(f, &history); err != nil {
+		return history, fmt.Errorf("failed to parse history file. %v", err)
+	}
+	if history.Params == nil {
+		history.Params = map[string]map[string][]string{}
+	}
+	return history, nil
+}
+
+// Save writes the parameter history to the history file, encrypted like snippet files
+func (history ParamHistory) Save() error {
+	file, err := historyFile()
+	if err != nil {
+		return err
+	}
+
+	var buffer bytes.Buffer
+	if err := toml.NewEncoder(&buffer).Encode(history); err != nil {
+		return fmt.Errorf("failed to encode history file. %v", err)
+	}
+	return WriteCopy(file.Get(), buffer.Bytes(), 0600)
+}
+
+// Values returns the values previously entered for the parameters of a snippet
+func (history ParamHistory) Values(id string) map[string][]string {
+	return history.Params[id]
+}
+
+// Record puts the values entered for the parameters of a snippet
+// at the front of their history, keeping at most size values per parameter
+func (history ParamHistory) Record(id string, values map[string]string, size int) {
+	if _, ok := history.Params[id]; !ok {
+		history.Params[id] = map[string][]string{}
+	}
+
+	for param, value := range values {
+		if value == "" {
+			continue
+		}
+
+		previous := slices.DeleteFunc(history.Params[id][param], func(v string) bool { return v == value })
+		entries := append([]string{value}, previous...)
+		if len(entries) > size {
+			entries = entries[:size]
+		}
+		history.Params[id][param] = entries
+	}
+}
+
+// RecordParams records the values entered for the parameters of a snippet in the history file,
+// except for the parameters declared with no_history
+func RecordParams(s SnippetInfo, values map[string]string) error {
+	size := paramHistorySize()
+	if size == 0 {
+		return nil
+	}
+
+	remembered := map[string]string{}
+	for param, value := range values {
+		if !s.Params[param].NoHistory {
+			remembered[param] = value
+		}
+	}
+	if len(remembered) == 0 {
+		return nil
+	}
+
+	history, err := LoadParamHistory()
+	if err != nil {
+		return err
+	}
+	history.Record(s.ID, remembered, size)
+	return history.Save()
+}
@@ -0,0 +1,84 @@
+package snippet
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/knqyf263/pet/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParamHistoryRecord(t *testing.T) {
+	history := ParamHistory{Params: map[string]map[string][]string{}}
+
+	history.Record("id-1", map[string]string{"port": "80"}, 3)
+	history.Record("id-1", map[string]string{"port": "443", "host": ""}, 3)
+	history.Record("id-1", map[string]string{"port": "8080"}, 3)
+	history.Record("id-1", map[string]string{"port": "80"}, 3)
+	history.Record("id-1", map[string]string{"port": "22"}, 3)
+
+	// Most recent first, without duplicates and empty values, capped to the size
+	assert.Equal(t, map[string][]string{"port": {"22", "80", "8080"}}, history.Values("id-1"))
+	assert.Nil(t, history.Values("id-2"))
+}
+
+func TestRecordParams(t *testing.T) {
+	tempDir, _ := os.MkdirTemp("", "testdata")
+	defer os.RemoveAll(tempDir)
+	defer func() { config.Conf.General.ParamHistorySize = 0 }()
+
+	// Mock configuration
+	config.Conf.General.ParamHistoryFile = filepath.Join(tempDir, "history.toml")
+
+	assert.NoError(t, RecordParams(SnippetInfo{ID: "id-1"}, map[string]string{"my param": "first"}))
+	assert.NoError(t, RecordParams(SnippetInfo{ID: "id-1"}, map[string]string{"my param": "second"}))
+
+	history, err := LoadParamHistory()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"second", "first"}, history.Values("id-1")["my param"])
+
+	// A negative size disables the history
+	config.Conf.General.ParamHistorySize = -1
+	assert.NoError(t, RecordParams(SnippetInfo{ID: "id-1"}, map[string]string{"my param": "third"}))
+
+	history, err = LoadParamHistory()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"second", "first"}, history.Values("id-1")["my param"])
+}
+
+func TestRecordParamsNoHistory(t *testing.T) {
+	tempDir := t.TempDir()
+
+	// Mock configuration
+	config.Conf.General.ParamHistoryFile = filepath.Join(tempDir, "history.toml")
+
+	s := SnippetInfo{ID: "id-1", Params: map[string]ParamSpec{"password": {NoHistory: true}}}
+	assert.NoError(t, RecordParams(s, map[string]string{"user": "admin", "password": "hunter2"}))
+
+	history, err := LoadParamHistory()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]string{"user": {"admin"}}, history.Values("id-1"))
+}
+
+func TestRecordParamsEncrypted(t *testing.T) {
+	setupEncryption(t, "correct horse")
+	orig := config.Conf
+	defer func() { config.Conf = orig }()
+
+	historyFile := filepath.Join(t.TempDir(), "history.toml")
+	config.Conf = config.Config{}
+	config.Conf.General.ParamHistoryFile = historyFile
+	config.Conf.Encryption.Files = true
+
+	assert.NoError(t, RecordParams(SnippetInfo{ID: "id-1"}, map[string]string{"host": "db.internal"}))
+
+	data, err := os.ReadFile(historyFile)
+	assert.NoError(t, err)
+	assert.True(t, IsEncrypted(data))
+	assert.NotContains(t, string(data), "db.internal")
+
+	history, err := LoadParamHistory()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"db.internal"}, history.Values("id-1")["host"])
+}
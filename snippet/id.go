@@ -0,0 +1,44 @@
+package snippet
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"time"
+)
+
+// crockford is the Crockford base32 alphabet used by ULIDs
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// NewID returns a new ULID (https://github.com/ulid/spec) which is used
+// to identify a snippet. IDs are lexicographically sortable by creation time.
+func NewID() string {
+	return newIDWithTime(time.Now())
+}
+
+func newIDWithTime(t time.Time) string {
+	var b [16]byte
+
+	// 48 bits of milliseconds since the epoch followed by 80 random bits
+	var ts [8]byte
+	binary.BigEndian.PutUint64(ts[:], uint64(t.UnixMilli()))
+	copy(b[:6], ts[2:])
+	if _, err := rand.Read(b[6:]); err != nil {
+		panic(err)
+	}
+
+	return encodeID(b)
+}
+
+// encodeID encodes 128 bits into 26 characters of Crockford base32
+func encodeID(b [16]byte) string {
+	hi := binary.BigEndian.Uint64(b[:8])
+	lo := binary.BigEndian.Uint64(b[8:])
+
+	var dst [26]byte
+	for i := 25; i >= 0; i-- {
+		dst[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(dst[:])
+}
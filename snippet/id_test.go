@@ -0,0 +1,40 @@
+package snippet
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewID(t *testing.T) {
+	id := NewID()
+	if len(id) != 26 {
+		t.Fatalf("Expected an ID of 26 characters, but got %q", id)
+	}
+
+	if NewID() == id {
+		t.Errorf("Expected IDs to be unique")
+	}
+}
+
+func TestNewIDWithTime_SortsByTime(t *testing.T) {
+	older := newIDWithTime(time.UnixMilli(1000))
+	newer := newIDWithTime(time.UnixMilli(2000))
+
+	if older[:10] >= newer[:10] {
+		t.Errorf("Expected %q to sort before %q", older, newer)
+	}
+}
+
+func TestEncodeID(t *testing.T) {
+	var b [16]byte
+	if got := encodeID(b); got != "00000000000000000000000000" {
+		t.Errorf("Expected zero ID, but got %q", got)
+	}
+
+	for i := range b {
+		b[i] = 0xff
+	}
+	if got := encodeID(b); got != "7ZZZZZZZZZZZZZZZZZZZZZZZZZ" {
+		t.Errorf("Expected max ID, but got %q", got)
+	}
+}
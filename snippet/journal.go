@@ -0,0 +1,461 @@
+package snippet
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/knqyf263/pet/config"
+	"github.com/knqyf263/pet/path"
+	"github.com/pelletier/go-toml"
+)
+
+// defaultJournalSize is how many versions are kept per snippet file by default
+const defaultJournalSize = 50
+
+// Reasons a version of a snippet file was written
+const (
+	ReasonOriginal = "original"
+	ReasonSave     = "save"
+	ReasonEdit     = "edit"
+	ReasonSync     = "sync"
+	ReasonConvert  = "convert"
+	ReasonRestore  = "restore"
+	ReasonUndo     = "undo"
+)
+
+// Version is a snapshot of a snippet file recorded in the journal
+type Version struct {
+	Version int       `toml:"version"`
+	File    string    `toml:"file"`
+	Time    time.Time `toml:"time"`
+	Reason  string    `toml:"reason"`
+	Deleted bool      `toml:"deleted,omitempty"`
+	// Created is set on the first version of a file which did not exist before
+	Created bool `toml:"created,omitempty"`
+	// Run identifies the pet command which wrote the version, undo rolls back all its versions
+	Run string `toml:"run,omitempty"`
+	// Restored is the version the file was rolled back to by a restore or an undo
+	Restored int `toml:"restored,omitempty"`
+	// Undone is the run rolled back by an undo
+	Undone string `toml:"undone,omitempty"`
+}
+
+// runID identifies the versions written by this pet command
+var runID = fmt.Sprintf("%d-%d", os.Getpid(), time.Now().UnixNano())
+
+// Journal is the local history of the snippet files, oldest version first.
+// Each version keeps the whole file in the journal directory, so it can be restored.
+type Journal struct {
+	Versions []Version `toml:"versions"`
+
+	dir string
+}
+
+// VersionDiff is the snippets added, removed and changed by a version
+// compared to the previous version of its file
+type VersionDiff struct {
+	Added   []SnippetInfo
+	Removed []SnippetInfo
+	Changed []SnippetInfo
+}
+
+// Empty reports whether the version did not change any snippet
+func (diff VersionDiff) Empty() bool {
+	return len(diff.Added) == 0 && len(diff.Removed) == 0 && len(diff.Changed) == 0
+}
+
+// journalDir returns the path of the local journal directory
+func journalDir() (path.AbsolutePath, error) {
+	return localFile(config.Conf.General.JournalDir, "journal")
+}
+
+// journalSize returns how many versions are kept per snippet file, 0 if disabled
+func journalSize() int {
+	size := config.Conf.General.JournalSize
+	if size == 0 {
+		return defaultJournalSize
+	} else if size < 0 {
+		return 0
+	}
+	return size
+}
+
+// LoadJournal loads the journal from the journal directory
+func LoadJournal() (Journal, error) {
+	dir, err := journalDir()
+	if err != nil {
+		return Journal{}, err
+	}
+	journal := Journal{dir: dir.Get()}
+
+	f, err := os.ReadFile(journal.indexFile())
+	if os.IsNotExist(err) {
+		return journal, nil
+	} else if err != nil {
+		return journal, fmt.Errorf("failed to load journal. %v", err)
+	}
+
+	if err := toml.Unmarshal(f, &journal); err != nil {
+		return journal, fmt.Errorf("failed to parse journal. %v", err)
+	}
+	return journal, nil
+}
+
+func (journal Journal) indexFile() string {
+	return filepath.Join(journal.dir, "journal.toml")
+}
+
+// snapshotFile returns the file keeping the content of a version
+func (journal Journal) snapshotFile(v Version) string {
+	return filepath.Join(journal.dir, fmt.Sprintf("%d%s", v.Version, filepath.Ext(v.File)))
+}
+
+func (journal Journal) save() error {
+	var buffer bytes.Buffer
+	if err := toml.NewEncoder(&buffer).Encode(journal); err != nil {
+		return fmt.Errorf("failed to encode journal. %v", err)
+	}
+	// The index holds no snippets, only the snapshots are encrypted
+	if err := os.WriteFile(journal.indexFile(), buffer.Bytes(), 0600); err != nil {
+		return fmt.Errorf("failed to save journal. %v", err)
+	}
+	return nil
+}
+
+// Find returns a version by its number
+func (journal Journal) Find(version int) (Version, bool) {
+	for _, v := range journal.Versions {
+		if v.Version == version {
+			return v, true
+		}
+	}
+	return Version{}, false
+}
+
+// Previous returns the version of the same file recorded before v
+func (journal Journal) Previous(v Version) (Version, bool) {
+	for i := len(journal.Versions) - 1; i >= 0; i-- {
+		prev := journal.Versions[i]
+		if prev.Version < v.Version && prev.File == v.File {
+			return prev, true
+		}
+	}
+	return Version{}, false
+}
+
+// latest returns the last version of a file
+func (journal Journal) latest(file string) (Version, bool) {
+	for i := len(journal.Versions) - 1; i >= 0; i-- {
+		if journal.Versions[i].File == file {
+			return journal.Versions[i], true
+		}
+	}
+	return Version{}, false
+}
+
+// Content returns the content of a snippet file as of a version
+func (journal Journal) Content(v Version) ([]byte, error) {
+	if v.Deleted {
+		return nil, nil
+	}
+	data, err := ReadFile(journal.snapshotFile(v))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read version %d. %v", v.Version, err)
+	}
+	return data, nil
+}
+
+// Diff returns the snippets changed by a version
+func (journal Journal) Diff(v Version) (VersionDiff, error) {
+	var before []byte
+	if prev, ok := journal.Previous(v); ok {
+		var err error
+		if before, err = journal.Content(prev); err != nil {
+			return VersionDiff{}, err
+		}
+	}
+	after, err := journal.Content(v)
+	if err != nil {
+		return VersionDiff{}, err
+	}
+	return diffVersions(v.File, before, after)
+}
+
+// diffVersions compares the snippets of two versions of a file. Snippets are matched
+// by ID, or by description and command for snippets saved before IDs existed.
+// The time of the last use is not a change.
+func diffVersions(file string, before, after []byte) (VersionDiff, error) {
+	format := FormatOf(file)
+	var old, cur Snippets
+	var err error
+	if old.Snippets, err = format.Decode(before); err != nil {
+		return VersionDiff{}, fmt.Errorf("failed to parse snippet file. %v", err)
+	}
+	if cur.Snippets, err = format.Decode(after); err != nil {
+		return VersionDiff{}, fmt.Errorf("failed to parse snippet file. %v", err)
+	}
+
+	var diff VersionDiff
+	matched := make([]bool, len(old.Snippets))
+	match := func(s SnippetInfo) int {
+		for i, o := range old.Snippets {
+			if !matched[i] && s.ID != "" && o.ID == s.ID {
+				return i
+			}
+		}
+		for i, o := range old.Snippets {
+			if !matched[i] && o.ID == "" && o.Description == s.Description && o.Command == s.Command {
+				return i
+			}
+		}
+		return -1
+	}
+
+	for _, s := range cur.Snippets {
+		i := match(s)
+		if i < 0 {
+			diff.Added = append(diff.Added, s)
+			continue
+		}
+		matched[i] = true
+
+		if !sameSnippet(old.Snippets[i], s) {
+			diff.Changed = append(diff.Changed, s)
+		}
+	}
+	for i, o := range old.Snippets {
+		if !matched[i] {
+			diff.Removed = append(diff.Removed, o)
+		}
+	}
+	return diff, nil
+}
+
+// sameSnippet reports whether a snippet is unchanged, apart from the ID assigned to it
+// and the time of its last use. Missing and empty tags or parameters are the same.
+func sameSnippet(old, cur SnippetInfo) bool {
+	old.ID, old.LastUsedAt = "", time.Time{}
+	cur.ID, cur.LastUsedAt = "", time.Time{}
+	return old.Equal(cur)
+}
+
+// RecordVersion records the content just written to a snippet file in the journal.
+// Content which changes no snippet but the time of their last use is not recorded,
+// unless it is recorded for a restore or an undo.
+func RecordVersion(file string, data []byte, reason string) error {
+	return recordVersion(Version{File: file, Reason: reason}, data)
+}
+
+// RecordDeletion records that a snippet file was deleted in the journal
+func RecordDeletion(file string, reason string) error {
+	return recordVersion(Version{File: file, Reason: reason, Deleted: true}, nil)
+}
+
+func recordVersion(v Version, data []byte) error {
+	size := journalSize()
+	if size == 0 {
+		return nil
+	}
+
+	unlock, err := Lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if v.File, err = filepath.Abs(v.File); err != nil {
+		return err
+	}
+	journal, err := LoadJournal()
+	if err != nil {
+		return err
+	}
+
+	latest, ok := journal.latest(v.File)
+	original := false
+	if v.Reason != ReasonOriginal {
+		v.Run = runID
+	}
+	if !ok {
+		// The first version of a file starts from the one it replaced
+		if previous, err := ReadFile(v.File + backupSuffix); err == nil {
+			if err := journal.add(Version{File: v.File, Reason: ReasonOriginal}, previous); err != nil {
+				return err
+			}
+			latest, ok = journal.latest(v.File)
+			original = true
+		} else if os.IsNotExist(err) && !v.Deleted {
+			v.Created = true
+		}
+	}
+	if ok && v.Reason != ReasonRestore && v.Reason != ReasonUndo {
+		content, err := journal.Content(latest)
+		if err != nil {
+			return err
+		}
+		if diff, err := diffVersions(v.File, content, data); err == nil && diff.Empty() && latest.Deleted == v.Deleted {
+			if original {
+				return journal.save()
+			}
+			return nil
+		}
+	}
+
+	if err := journal.add(v, data); err != nil {
+		return err
+	}
+	journal.prune(v.File, size)
+	return journal.save()
+}
+
+// add keeps the content of a new version in the journal directory
+func (journal *Journal) add(v Version, data []byte) error {
+	v.Version = 1
+	if len(journal.Versions) > 0 {
+		v.Version = journal.Versions[len(journal.Versions)-1].Version + 1
+	}
+	v.Time = Now()
+
+	if !v.Deleted {
+		if err := os.MkdirAll(journal.dir, 0700); err != nil {
+			return fmt.Errorf("failed to create journal directory. %v", err)
+		}
+		if err := WriteCopy(journal.snapshotFile(v), data, 0600); err != nil {
+			return fmt.Errorf("failed to save version %d. %v", v.Version, err)
+		}
+	}
+	journal.Versions = append(journal.Versions, v)
+	return nil
+}
+
+// prune drops the oldest versions of a file beyond size
+func (journal *Journal) prune(file string, size int) {
+	count := 0
+	for _, v := range journal.Versions {
+		if v.File == file {
+			count++
+		}
+	}
+
+	journal.Versions = slices.DeleteFunc(journal.Versions, func(v Version) bool {
+		if v.File != file || count <= size {
+			return false
+		}
+		count--
+		os.Remove(journal.snapshotFile(v))
+		return true
+	})
+}
+
+// Restore rolls a snippet file back to a version
+func Restore(version int) (Version, error) {
+	unlock, err := Lock()
+	if err != nil {
+		return Version{}, err
+	}
+	defer unlock()
+
+	journal, err := LoadJournal()
+	if err != nil {
+		return Version{}, err
+	}
+	v, ok := journal.Find(version)
+	if !ok {
+		return Version{}, fmt.Errorf("version %d not found", version)
+	}
+	return v, journal.restore(v, ReasonRestore, "")
+}
+
+// Undo rolls back the files changed by the last pet command to their previous versions.
+// Undoing again rolls back the command before it.
+func Undo() ([]Version, error) {
+	unlock, err := Lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	journal, err := LoadJournal()
+	if err != nil {
+		return nil, err
+	}
+
+	run := journal.lastRun()
+	if run == "" {
+		return nil, fmt.Errorf("nothing to undo")
+	}
+
+	// Each file goes back to its version before the first one written by the run,
+	// files created by the run are deleted
+	var targets []Version
+	seen := map[string]bool{}
+	for _, v := range journal.Versions {
+		if v.Run != run || seen[v.File] {
+			continue
+		}
+		seen[v.File] = true
+		if prev, ok := journal.Previous(v); ok {
+			targets = append(targets, prev)
+		} else if v.Created {
+			targets = append(targets, Version{File: v.File, Deleted: true})
+		}
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("nothing to undo, the files changed last have no previous version")
+	}
+
+	for _, v := range targets {
+		if err := journal.restore(v, ReasonUndo, run); err != nil {
+			return nil, err
+		}
+	}
+	return targets, nil
+}
+
+// lastRun returns the last pet command which changed snippet files and was not undone yet
+func (journal Journal) lastRun() string {
+	undone := map[string]bool{}
+	for _, v := range journal.Versions {
+		if v.Undone != "" {
+			undone[v.Undone] = true
+		}
+	}
+
+	for i := len(journal.Versions) - 1; i >= 0; i-- {
+		v := journal.Versions[i]
+		if v.Run != "" && v.Reason != ReasonUndo && !undone[v.Run] {
+			return v.Run
+		}
+	}
+	return ""
+}
+
+// restore writes the content of a version to its file and records it as a new version
+func (journal Journal) restore(v Version, reason string, undone string) error {
+	restored := Version{File: v.File, Reason: reason, Deleted: v.Deleted, Restored: v.Version, Undone: undone}
+	if v.Deleted {
+		if err := backupFile(v.File); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to back up %s. %v", v.File, err)
+		}
+		if err := os.Remove(v.File); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return recordVersion(restored, nil)
+	}
+
+	data, err := journal.Content(v)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(v.File), 0700); err != nil {
+		return err
+	}
+	if err := WriteFile(v.File, data, 0666); err != nil {
+		return fmt.Errorf("failed to restore %s. %v", v.File, err)
+	}
+	return recordVersion(restored, data)
+}
@@ -0,0 +1,256 @@
+package snippet
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/knqyf263/pet/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMain keeps the journal and other local files of the tests out of the user's config directory
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "pet-config")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv("PET_CONFIG_DIR", dir)
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func setupJournal(t *testing.T) string {
+	orig := config.Conf
+	t.Cleanup(func() { config.Conf = orig })
+
+	dir := t.TempDir()
+	config.Conf = config.Config{}
+	config.Conf.General.SnippetFile = filepath.Join(dir, "snippet.toml")
+	config.Conf.General.JournalDir = filepath.Join(dir, "journal")
+
+	origRun := runID
+	t.Cleanup(func() { runID = origRun })
+	return config.Conf.General.SnippetFile
+}
+
+// newRun makes the following versions look written by another pet command
+func newRun() {
+	runID += "+"
+}
+
+// saveTestSnippets saves a snippet file as a pet command of its own
+func saveTestSnippets(t *testing.T, file string, snippets ...SnippetInfo) {
+	newRun()
+	for i := range snippets {
+		snippets[i].Filename = file
+	}
+	s := Snippets{Snippets: snippets}
+	require.NoError(t, s.Save())
+}
+
+func loadJournalVersions(t *testing.T) []Version {
+	journal, err := LoadJournal()
+	require.NoError(t, err)
+	return journal.Versions
+}
+
+func TestRecordVersion(t *testing.T) {
+	file := setupJournal(t)
+
+	saveTestSnippets(t, file, SnippetInfo{ID: "a", Description: "list", Command: "ls"})
+	saveTestSnippets(t, file,
+		SnippetInfo{ID: "a", Description: "list all", Command: "ls -a"},
+		SnippetInfo{ID: "b", Description: "disk", Command: "df"})
+	// Using a snippet is not a new version
+	saveTestSnippets(t, file,
+		SnippetInfo{ID: "a", Description: "list all", Command: "ls -a", LastUsedAt: time.Now()},
+		SnippetInfo{ID: "b", Description: "disk", Command: "df"})
+	saveTestSnippets(t, file, SnippetInfo{ID: "b", Description: "disk", Command: "df"})
+
+	journal, err := LoadJournal()
+	require.NoError(t, err)
+	require.Len(t, journal.Versions, 3)
+	for i, v := range journal.Versions {
+		assert.Equal(t, i+1, v.Version)
+		assert.Equal(t, file, v.File)
+		assert.Equal(t, ReasonSave, v.Reason)
+	}
+
+	diff, err := journal.Diff(journal.Versions[0])
+	require.NoError(t, err)
+	require.Len(t, diff.Added, 1)
+	assert.Equal(t, "list", diff.Added[0].Description)
+
+	diff, err = journal.Diff(journal.Versions[1])
+	require.NoError(t, err)
+	require.Len(t, diff.Added, 1)
+	assert.Equal(t, "disk", diff.Added[0].Description)
+	require.Len(t, diff.Changed, 1)
+	assert.Equal(t, "list all", diff.Changed[0].Description)
+	assert.Empty(t, diff.Removed)
+
+	diff, err = journal.Diff(journal.Versions[2])
+	require.NoError(t, err)
+	require.Len(t, diff.Removed, 1)
+	assert.Equal(t, "list all", diff.Removed[0].Description)
+	assert.Empty(t, diff.Added)
+	assert.Empty(t, diff.Changed)
+}
+
+func TestRecordVersionStartsFromBackup(t *testing.T) {
+	file := setupJournal(t)
+	require.NoError(t, os.WriteFile(file, []byte("[[Snippets]]\n  Description = \"list\"\n  command = \"ls\"\n"), 0600))
+
+	var snippets Snippets
+	require.NoError(t, snippets.Load(false))
+	snippets.Snippets = append(snippets.Snippets, SnippetInfo{Description: "disk", Command: "df"})
+	require.NoError(t, snippets.Save())
+
+	// The IDs assigned on load are no change of the original snippets
+	versions := loadJournalVersions(t)
+	require.Len(t, versions, 2)
+	assert.Equal(t, ReasonOriginal, versions[0].Reason)
+	assert.Equal(t, ReasonSave, versions[1].Reason)
+
+	journal, err := LoadJournal()
+	require.NoError(t, err)
+	diff, err := journal.Diff(versions[1])
+	require.NoError(t, err)
+	require.Len(t, diff.Added, 1)
+	assert.Equal(t, "disk", diff.Added[0].Description)
+	assert.Empty(t, diff.Changed)
+}
+
+func TestRecordVersionPrunes(t *testing.T) {
+	file := setupJournal(t)
+	config.Conf.General.JournalSize = 2
+
+	for _, command := range []string{"ls", "df", "ps"} {
+		saveTestSnippets(t, file, SnippetInfo{ID: "a", Command: command})
+	}
+
+	versions := loadJournalVersions(t)
+	require.Len(t, versions, 2)
+	assert.Equal(t, 2, versions[0].Version)
+	assert.Equal(t, 3, versions[1].Version)
+	assert.NoFileExists(t, filepath.Join(config.Conf.General.JournalDir, "1.toml"))
+	assert.FileExists(t, filepath.Join(config.Conf.General.JournalDir, "2.toml"))
+}
+
+func TestRecordVersionDisabled(t *testing.T) {
+	file := setupJournal(t)
+	config.Conf.General.JournalSize = -1
+
+	saveTestSnippets(t, file, SnippetInfo{ID: "a", Command: "ls"})
+	assert.NoDirExists(t, config.Conf.General.JournalDir)
+}
+
+func TestRestore(t *testing.T) {
+	file := setupJournal(t)
+	saveTestSnippets(t, file, SnippetInfo{ID: "a", Command: "ls"})
+	saveTestSnippets(t, file, SnippetInfo{ID: "a", Command: "df"})
+
+	v, err := Restore(1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, v.Version)
+
+	var snippets Snippets
+	require.NoError(t, snippets.Load(false))
+	require.Len(t, snippets.Snippets, 1)
+	assert.Equal(t, "ls", snippets.Snippets[0].Command)
+
+	versions := loadJournalVersions(t)
+	require.Len(t, versions, 3)
+	assert.Equal(t, ReasonRestore, versions[2].Reason)
+	assert.Equal(t, 1, versions[2].Restored)
+
+	_, err = Restore(42)
+	assert.EqualError(t, err, "version 42 not found")
+}
+
+func TestUndo(t *testing.T) {
+	file := setupJournal(t)
+	require.NoError(t, os.WriteFile(file, []byte("[[Snippets]]\n  id = \"a\"\n  command = \"ls\"\n"), 0600))
+	for _, command := range []string{"df", "ps"} {
+		saveTestSnippets(t, file, SnippetInfo{ID: "a", Command: command})
+	}
+
+	command := func() string {
+		var snippets Snippets
+		require.NoError(t, snippets.Load(false))
+		require.Len(t, snippets.Snippets, 1)
+		return snippets.Snippets[0].Command
+	}
+
+	// Undoing again goes further back
+	restored, err := Undo()
+	require.NoError(t, err)
+	require.Len(t, restored, 1)
+	assert.Equal(t, 2, restored[0].Version)
+	assert.Equal(t, "df", command())
+
+	restored, err = Undo()
+	require.NoError(t, err)
+	require.Len(t, restored, 1)
+	assert.Equal(t, 1, restored[0].Version)
+	assert.Equal(t, "ls", command())
+
+	_, err = Undo()
+	assert.ErrorContains(t, err, "nothing to undo")
+
+	// A change after an undo is undone first
+	saveTestSnippets(t, file, SnippetInfo{ID: "a", Command: "top"})
+	restored, err = Undo()
+	require.NoError(t, err)
+	require.Len(t, restored, 1)
+	assert.Equal(t, ReasonUndo, restored[0].Reason)
+	assert.Equal(t, "ls", command())
+}
+
+func TestUndoCommandChangingFiles(t *testing.T) {
+	file := setupJournal(t)
+	other := filepath.Join(filepath.Dir(file), "other.toml")
+	saveTestSnippets(t, file, SnippetInfo{ID: "a", Command: "ls"})
+	saveTestSnippets(t, other, SnippetInfo{ID: "b", Command: "df"})
+
+	// One command changes both files, more than once
+	newRun()
+	for _, command := range []string{"ps", "top"} {
+		s := Snippets{Snippets: []SnippetInfo{
+			{ID: "a", Command: command, Filename: file},
+			{ID: "b", Command: command, Filename: other},
+		}}
+		require.NoError(t, s.Save())
+	}
+
+	restored, err := Undo()
+	require.NoError(t, err)
+	assert.Len(t, restored, 2)
+
+	var snippets Snippets
+	require.NoError(t, snippets.Load(false))
+	require.Len(t, snippets.Snippets, 1)
+	assert.Equal(t, "ls", snippets.Snippets[0].Command)
+	assert.Contains(t, readTestFile(t, other), `command = "df"`)
+}
+
+func TestRestoreDeletedFile(t *testing.T) {
+	file := setupJournal(t)
+	other := filepath.Join(filepath.Dir(file), "other.toml")
+	saveTestSnippets(t, other, SnippetInfo{ID: "a", Command: "ls"})
+	require.NoError(t, os.Rename(other, other+backupSuffix))
+	newRun()
+	require.NoError(t, RecordDeletion(other, ReasonSync))
+
+	_, err := Undo()
+	require.NoError(t, err)
+	assert.Contains(t, readTestFile(t, other), `command = "ls"`)
+
+	versions := loadJournalVersions(t)
+	require.Len(t, versions, 3)
+	assert.True(t, versions[1].Deleted)
+}
@@ -0,0 +1,133 @@
+package snippet
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/knqyf263/pet/config"
+	"github.com/knqyf263/pet/path"
+)
+
+const (
+	defaultLockTimeout = 10 * time.Second
+	lockRetryInterval  = 50 * time.Millisecond
+)
+
+var (
+	lockMu    sync.Mutex
+	lockDepth int
+	lockFile  *os.File
+)
+
+// Lock takes the lock of the snippet files, so that other pet processes wait for their
+// changes instead of overwriting them. It waits up to locktimeout seconds for the lock
+// held by another pet. Locks taken again by the same process nest, each must be released
+// by calling the returned unlock. Calling unlock again does nothing.
+func Lock() (unlock func(), err error) {
+	lockMu.Lock()
+	defer lockMu.Unlock()
+
+	if lockDepth > 0 {
+		lockDepth++
+		return unlockOnce(), nil
+	}
+
+	name, err := lockFileName()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file. %v", err)
+	}
+
+	timeout := defaultLockTimeout
+	if config.Conf.General.LockTimeout > 0 {
+		timeout = time.Duration(config.Conf.General.LockTimeout) * time.Second
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		locked, err := tryLockFile(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to lock snippet files. %v", err)
+		}
+		if locked {
+			break
+		}
+		if time.Now().After(deadline) {
+			holder, _ := os.ReadFile(name)
+			f.Close()
+			return nil, fmt.Errorf("snippet files are locked by %s, gave up after %s",
+				describeHolder(string(holder)), timeout)
+		}
+		time.Sleep(lockRetryInterval)
+	}
+
+	// Tell whoever waits for the lock who holds it
+	if err := f.Truncate(0); err == nil {
+		f.WriteAt([]byte(holderLine()), 0)
+	}
+
+	lockFile = f
+	lockDepth = 1
+	return unlockOnce(), nil
+}
+
+// unlockOnce returns an unlock releasing one level of the lock, however often it is called
+func unlockOnce() func() {
+	var once sync.Once
+	return func() { once.Do(releaseLock) }
+}
+
+func releaseLock() {
+	lockMu.Lock()
+	defer lockMu.Unlock()
+
+	if lockDepth == 0 {
+		return
+	}
+	lockDepth--
+	if lockDepth > 0 {
+		return
+	}
+
+	lockFile.Truncate(0)
+	unlockFile(lockFile)
+	lockFile.Close()
+	lockFile = nil
+}
+
+// lockFileName returns the lock file, a hidden file next to the main snippet file
+func lockFileName() (string, error) {
+	file, err := path.NewAbsolutePath(config.Conf.General.SnippetFile)
+	if err != nil {
+		return "", err
+	}
+	dir, base := filepath.Split(file.Get())
+	return filepath.Join(dir, "."+base+".lock"), nil
+}
+
+// holderLine describes this process for the lock file
+func holderLine() string {
+	command := append([]string{filepath.Base(os.Args[0])}, os.Args[1:]...)
+	return fmt.Sprintf("%s\t%d\t%s\n", strings.Join(command, " "), os.Getpid(), time.Now().Format(time.RFC3339))
+}
+
+// describeHolder turns the line written by holderLine into a message
+func describeHolder(line string) string {
+	fields := strings.Split(strings.TrimSpace(line), "\t")
+	if len(fields) != 3 {
+		return "another pet"
+	}
+
+	description := fmt.Sprintf("`%s` (pid %s)", fields[0], fields[1])
+	if since, err := time.Parse(time.RFC3339, fields[2]); err == nil {
+		description += " since " + since.Format("15:04:05")
+	}
+	return description
+}
@@ -0,0 +1,109 @@
+package snippet
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/knqyf263/pet/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupLock(t *testing.T) string {
+	orig := config.Conf
+	t.Cleanup(func() { config.Conf = orig })
+
+	config.Conf = config.Config{}
+	config.Conf.General.SnippetFile = filepath.Join(t.TempDir(), "snippet.toml")
+	config.Conf.General.LockTimeout = 1
+
+	name, err := lockFileName()
+	require.NoError(t, err)
+	return name
+}
+
+func TestLockNests(t *testing.T) {
+	name := setupLock(t)
+
+	unlock, err := Lock()
+	require.NoError(t, err)
+	unlockInner, err := Lock()
+	require.NoError(t, err)
+	unlockInner()
+	unlockInner() // a deferred unlock after an explicit one does not release the outer lock
+
+	// Still held by the outer lock
+	other, err := os.OpenFile(name, os.O_RDWR, 0600)
+	require.NoError(t, err)
+	defer other.Close()
+	locked, err := tryLockFile(other)
+	require.NoError(t, err)
+	assert.False(t, locked)
+
+	unlock()
+	locked, err = tryLockFile(other)
+	require.NoError(t, err)
+	assert.True(t, locked)
+	require.NoError(t, unlockFile(other))
+}
+
+func TestLockTimeout(t *testing.T) {
+	name := setupLock(t)
+
+	// Another pet holds the lock
+	other, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE, 0600)
+	require.NoError(t, err)
+	defer other.Close()
+	locked, err := tryLockFile(other)
+	require.NoError(t, err)
+	require.True(t, locked)
+	_, err = other.WriteString("pet new\t4242\t2024-05-01T13:14:15Z\n")
+	require.NoError(t, err)
+
+	start := time.Now()
+	_, err = Lock()
+	require.Error(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), time.Second)
+	assert.Equal(t, "snippet files are locked by `pet new` (pid 4242) since 13:14:15, gave up after 1s", err.Error())
+
+	// Waiting ends once the other pet releases the lock
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		unlockFile(other)
+	}()
+	unlock, err := Lock()
+	require.NoError(t, err)
+	unlock()
+}
+
+func TestDescribeHolder(t *testing.T) {
+	assert.Equal(t, "another pet", describeHolder(""))
+	assert.Equal(t, "`pet sync` (pid 12)", describeHolder("pet sync\t12\tyesterday\n"))
+}
+
+func TestLoadWhileLocked(t *testing.T) {
+	name := setupLock(t)
+	require.NoError(t, os.WriteFile(config.Conf.General.SnippetFile, []byte("[[Snippets]]\n  id = \"1\"\n  command = \"ls\"\n"), 0644))
+
+	// Another pet holds the lock
+	other, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE, 0600)
+	require.NoError(t, err)
+	defer other.Close()
+	locked, err := tryLockFile(other)
+	require.NoError(t, err)
+	require.True(t, locked)
+
+	// Reading doesn't wait for it
+	var snippets Snippets
+	start := time.Now()
+	require.NoError(t, snippets.Load(true))
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, "ls", snippets.Snippets[0].Command)
+
+	// Saving backfilled IDs does
+	require.NoError(t, os.WriteFile(config.Conf.General.SnippetFile, []byte("[[Snippets]]\n  command = \"ls\"\n"), 0644))
+	assert.ErrorContains(t, snippets.Load(true), "snippet files are locked")
+	require.NoError(t, snippets.LoadWithoutSaving(true))
+}
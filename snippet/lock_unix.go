@@ -0,0 +1,21 @@
+//go:build !windows
+
+package snippet
+
+import (
+	"os"
+	"syscall"
+)
+
+// tryLockFile takes the exclusive advisory lock of the file if nobody holds it
+func tryLockFile(f *os.File) (bool, error) {
+	err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err == syscall.EWOULDBLOCK {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func unlockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
@@ -0,0 +1,204 @@
+package snippet
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// errReadOnly is returned when encoding snippets in a format pet only reads
+var errReadOnly = errors.New("Markdown snippet files are read-only, edit them in your editor")
+
+// shellLanguages are the info strings of fenced code blocks holding commands,
+// blocks in other languages are examples or configuration
+var shellLanguages = []string{"", "sh", "bash", "zsh", "fish", "shell", "console", "powershell", "pwsh", "ps1", "bat", "cmd"}
+
+var (
+	headingRegEx = regexp.MustCompile(`^ {0,3}#{1,6}\s+(.*?)(\s+#+)?\s*$`)
+	fenceRegEx   = regexp.MustCompile("^ {0,3}(```+|~~~+)\\s*([^\\s`]*)")
+	tagLineRegEx = regexp.MustCompile(`(?i)^\s*tags?:\s*(.*)$`)
+)
+
+// markdownFormat reads cheatsheets written in Markdown. Each heading is the description
+// of the commands in the fenced code blocks below it, a "Tags:" line below the heading
+// or tags in the front matter of the file tag them. A code block marked "output"
+// is the output of the command before it.
+type markdownFormat struct {
+	// file names the snippets, so that their IDs stay the same across runs
+	file string
+}
+
+func (md markdownFormat) Decode(data []byte) ([]SnippetInfo, error) {
+	body, fileTags, err := splitFrontMatter(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var snippets []SnippetInfo
+	section := 0 // where the snippets below the current heading start
+	description := ""
+	var sectionTags []string
+	endSection := func() {
+		for i := section; i < len(snippets); i++ {
+			snippets[i].Tag = appendTags(append([]string{}, fileTags...), sectionTags...)
+		}
+		section = len(snippets)
+		sectionTags = nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if m := fenceRegEx.FindStringSubmatch(line); m != nil {
+			fence, language := m[1], strings.ToLower(m[2])
+			var code []string
+			for scanner.Scan() {
+				if strings.HasPrefix(strings.TrimSpace(scanner.Text()), fence) {
+					break
+				}
+				code = append(code, scanner.Text())
+			}
+
+			content := strings.Join(code, "\n")
+			switch {
+			case language == "output":
+				if len(snippets) > section {
+					snippets[len(snippets)-1].Output = content
+				}
+			case slices.Contains(shellLanguages, language) && strings.TrimSpace(content) != "":
+				snippets = append(snippets, SnippetInfo{Description: description, Command: content})
+			}
+			continue
+		}
+
+		if m := headingRegEx.FindStringSubmatch(line); m != nil {
+			endSection()
+			description = m[1]
+			continue
+		}
+		if m := tagLineRegEx.FindStringSubmatch(line); m != nil {
+			sectionTags = appendTags(sectionTags, strings.FieldsFunc(m[1], isTagSeparator)...)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	endSection()
+
+	// The same command under the same heading is one snippet, with one ID
+	var unique []SnippetInfo
+	seen := map[string]bool{}
+	for _, s := range snippets {
+		s.ID = md.snippetID(s)
+		if !seen[s.ID] {
+			seen[s.ID] = true
+			unique = append(unique, s)
+		}
+	}
+	return unique, nil
+}
+
+func (markdownFormat) Encode(snippets []SnippetInfo) ([]byte, error) {
+	return nil, errReadOnly
+}
+
+// snippetID derives the ID of a snippet from the file, its description and its command
+func (md markdownFormat) snippetID(s SnippetInfo) string {
+	sum := sha256.Sum256([]byte(md.file + "\x00" + s.Description + "\x00" + s.Command))
+	var b [16]byte
+	copy(b[:], sum[:])
+	return encodeID(b)
+}
+
+// splitFrontMatter returns the Markdown after the YAML front matter and the tags it declares,
+// as a list or as a string of tags. Windows line endings are turned into \n.
+func splitFrontMatter(data []byte) (body []byte, tags []string, err error) {
+	data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+	rest, ok := bytes.CutPrefix(data, []byte("---\n"))
+	if !ok {
+		return data, nil, nil
+	}
+	matter, body, ok := bytes.Cut(rest, []byte("\n---\n"))
+	if !ok {
+		return data, nil, nil
+	}
+
+	var front struct {
+		Tags yaml.Node `yaml:"tags"`
+		Tag  yaml.Node `yaml:"tag"`
+	}
+	if err := yaml.Unmarshal(matter, &front); err != nil {
+		return nil, nil, err
+	}
+	for _, node := range []yaml.Node{front.Tags, front.Tag} {
+		switch node.Kind {
+		case yaml.ScalarNode:
+			tags = appendTags(tags, strings.FieldsFunc(node.Value, isTagSeparator)...)
+		case yaml.SequenceNode:
+			for _, item := range node.Content {
+				tags = appendTags(tags, item.Value)
+			}
+		}
+	}
+	return body, tags, nil
+}
+
+func isTagSeparator(r rune) bool {
+	return r == ',' || r == ' ' || r == '\t'
+}
+
+// appendTags appends tags without their # or backquotes, leaving out duplicates
+func appendTags(tags []string, more ...string) []string {
+	for _, tag := range more {
+		tag = strings.Trim(tag, "#` ")
+		if tag != "" && !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// CheckWritable returns an error if pet must not change the snippets of a snippet file
+func CheckWritable(file string) error {
+	if IsReadOnly(file) {
+		return fmt.Errorf("cannot change snippets of %s. %v", file, errReadOnly)
+	}
+	return nil
+}
+
+// checkUnchanged returns an error if saving snippets would change a read-only snippet file
+func checkUnchanged(file string, snippets []SnippetInfo) error {
+	data, err := ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("failed to load snippet file. %v", err)
+	}
+	current, err := FormatOf(file).Decode(data)
+	if err != nil {
+		return fmt.Errorf("failed to parse snippet file. %v", err)
+	}
+
+	byID := map[string]SnippetInfo{}
+	for _, s := range current {
+		byID[s.ID] = s
+	}
+	for _, s := range snippets {
+		s.Filename = ""
+		if old, ok := byID[s.ID]; !ok || !sameSnippet(old, s) {
+			return CheckWritable(file)
+		}
+		delete(byID, s.ID)
+	}
+	if len(byID) > 0 {
+		return CheckWritable(file)
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package snippet
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/knqyf263/pet/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testRunbook = `---
+title: Deploy
+tags: [deploy, ops]
+---
+# Deploy runbook
+
+Some prose which is not a snippet.
+
+## Restart the service ##
+
+Tags: #systemd, prod
+
+` + "```bash" + `
+sudo systemctl restart <service=app>
+` + "```" + `
+
+` + "```output" + `
+(nothing)
+` + "```" + `
+
+## Configuration
+
+` + "```yaml" + `
+replicas: 3
+` + "```" + `
+
+## Tail the logs
+
+` + "~~~" + `
+journalctl -fu app
+~~~
+
+` + "```sh" + `
+journalctl -fu app
+` + "```" + `
+`
+
+func TestMarkdownFormat(t *testing.T) {
+	format := FormatOf("/runbooks/deploy.md")
+	snippets, err := format.Decode([]byte(testRunbook))
+	require.NoError(t, err)
+	require.Len(t, snippets, 2)
+
+	assert.Equal(t, "Restart the service", snippets[0].Description)
+	assert.Equal(t, "sudo systemctl restart <service=app>", snippets[0].Command)
+	assert.Equal(t, "(nothing)", snippets[0].Output)
+	assert.Equal(t, []string{"deploy", "ops", "systemd", "prod"}, snippets[0].Tag)
+
+	// The same command twice under a heading is one snippet
+	assert.Equal(t, "Tail the logs", snippets[1].Description)
+	assert.Equal(t, "journalctl -fu app", snippets[1].Command)
+	assert.Equal(t, []string{"deploy", "ops"}, snippets[1].Tag)
+
+	// IDs stay the same across runs, but differ between files
+	again, err := format.Decode([]byte(testRunbook))
+	require.NoError(t, err)
+	assert.Equal(t, snippets[0].ID, again[0].ID)
+	assert.NotEqual(t, snippets[0].ID, snippets[1].ID)
+	other, err := FormatOf("/runbooks/rollback.md").Decode([]byte(testRunbook))
+	require.NoError(t, err)
+	assert.NotEqual(t, snippets[0].ID, other[0].ID)
+
+	_, err = format.Encode(snippets)
+	assert.ErrorIs(t, err, errReadOnly)
+	assert.True(t, IsReadOnly("/runbooks/deploy.md"))
+	assert.False(t, IsReadOnly("/runbooks/deploy.toml"))
+}
+
+func TestMarkdownFrontMatterTags(t *testing.T) {
+	snippets, err := FormatOf("notes.md").Decode([]byte("---\ntags: git, vcs\n---\n# Status\n```\ngit status\n```\n"))
+	require.NoError(t, err)
+	require.Len(t, snippets, 1)
+	assert.Equal(t, []string{"git", "vcs"}, snippets[0].Tag)
+}
+
+func TestMarkdownWindowsLineEndings(t *testing.T) {
+	format := FormatOf("/runbooks/deploy.md")
+	snippets, err := format.Decode([]byte(testRunbook))
+	require.NoError(t, err)
+	crlf, err := format.Decode([]byte(strings.ReplaceAll(testRunbook, "\n", "\r\n")))
+	require.NoError(t, err)
+	assert.Equal(t, snippets, crlf)
+}
+
+func TestLoadAndSaveMarkdown(t *testing.T) {
+	file := setupJournal(t)
+	dir := filepath.Join(filepath.Dir(file), "runbooks")
+	require.NoError(t, os.MkdirAll(dir, 0700))
+	config.Conf.General.SnippetDirs = []string{dir}
+
+	require.NoError(t, os.WriteFile(file, []byte("[[Snippets]]\n  id = \"a\"\n  command = \"ls\"\n"), 0600))
+	runbook := filepath.Join(dir, "deploy.md")
+	require.NoError(t, os.WriteFile(runbook, []byte(testRunbook), 0600))
+
+	var snippets Snippets
+	require.NoError(t, snippets.Load(true))
+	require.Len(t, snippets.Snippets, 3)
+	assert.Equal(t, testRunbook, readTestFile(t, runbook))
+
+	var restart SnippetInfo
+	for _, s := range snippets.Snippets {
+		if s.Filename == runbook && s.Description == "Restart the service" {
+			restart = s
+		}
+	}
+	require.NotEmpty(t, restart.ID)
+
+	// Saving other changes leaves the Markdown file alone
+	s, _ := snippets.FindByID("a")
+	s.Command = "ls -a"
+	require.True(t, snippets.Update(s))
+	restart.LastUsedAt = time.Now()
+	require.True(t, snippets.Update(restart))
+	require.NoError(t, snippets.Save())
+	assert.Contains(t, readTestFile(t, file), `command = "ls -a"`)
+	assert.Equal(t, testRunbook, readTestFile(t, runbook))
+
+	// Changing a Markdown snippet saves nothing
+	restart.Command = "reboot"
+	require.True(t, snippets.Update(restart))
+	s.Command = "ls -l"
+	require.True(t, snippets.Update(s))
+	assert.ErrorContains(t, snippets.Save(), "cannot change snippets of "+runbook)
+	assert.Contains(t, readTestFile(t, file), `command = "ls -a"`)
+
+	var reloaded Snippets
+	require.NoError(t, reloaded.Load(true))
+	reloaded.Remove(restart.ID)
+	assert.ErrorContains(t, reloaded.Save(), "Markdown snippet files are read-only")
+	assert.Equal(t, testRunbook, readTestFile(t, runbook))
+}
@@ -0,0 +1,209 @@
+package snippet
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/pelletier/go-toml"
+)
+
+// Conflict is a snippet that was changed differently on both sides of a merge.
+// Local or Remote is nil when the snippet was deleted on that side.
+type Conflict struct {
+	Base   *SnippetInfo
+	Local  *SnippetInfo
+	Remote *SnippetInfo
+}
+
+// Parse parses the contents of a snippet file
+func Parse(content string) ([]SnippetInfo, error) {
+	var snippets Snippets
+	if err := toml.Unmarshal([]byte(content), &snippets); err != nil {
+		return nil, fmt.Errorf("failed to parse snippets. %v", err)
+	}
+	return snippets.Snippets, nil
+}
+
+// Merge combines the changes made locally and remotely since base, snippet by snippet.
+// Snippets are matched by ID, snippets without one are matched with a local snippet
+// having the same description and command. Changes to different snippets, or the same
+// change on both sides, are merged; anything else is returned as a conflict.
+// Merged snippets keep the local order, followed by the snippets added remotely.
+func Merge(base, local, remote []SnippetInfo) (merged []SnippetInfo, conflicts []Conflict) {
+	base = matchIDs(base, local)
+	remote = matchIDs(remote, local)
+	local = adoptRemoteIDs(base, local, remote)
+
+	baseByID := indexByID(base)
+	localByID := indexByID(local)
+	remoteByID := indexByID(remote)
+
+	var ids []string
+	seen := map[string]bool{}
+	for _, snippets := range [][]SnippetInfo{local, remote, base} {
+		for _, s := range snippets {
+			if !seen[s.ID] {
+				seen[s.ID] = true
+				ids = append(ids, s.ID)
+			}
+		}
+	}
+
+	for _, id := range ids {
+		b, l, r := baseByID[id], localByID[id], remoteByID[id]
+
+		switch {
+		case l == nil && r == nil:
+			// deleted on both sides
+		case l != nil && r != nil && sameContent(*l, *r):
+			merged = append(merged, mergeTimestamps(*l, *r))
+		case b == nil && r == nil:
+			merged = append(merged, *l)
+		case b == nil && l == nil:
+			merged = append(merged, *r)
+		case b == nil:
+			conflicts = append(conflicts, Conflict{Local: l, Remote: r})
+		case l == nil:
+			if !sameContent(*b, *r) {
+				conflicts = append(conflicts, Conflict{Base: b, Remote: r})
+			}
+		case r == nil:
+			if !sameContent(*b, *l) {
+				conflicts = append(conflicts, Conflict{Base: b, Local: l})
+			}
+		case sameContent(*b, *l):
+			s := mergeTimestamps(*r, *l)
+			s.Filename = l.Filename
+			merged = append(merged, s)
+		case sameContent(*b, *r):
+			merged = append(merged, mergeTimestamps(*l, *r))
+		default:
+			conflicts = append(conflicts, Conflict{Base: b, Local: l, Remote: r})
+		}
+	}
+	return merged, conflicts
+}
+
+// matchIDs returns a copy of snippets where snippets without an ID get the ID
+// of the local snippet with the same description and command, or a new one
+func matchIDs(snippets []SnippetInfo, local []SnippetInfo) []SnippetInfo {
+	matched := make([]SnippetInfo, len(snippets))
+	copy(matched, snippets)
+
+	for i, s := range matched {
+		if s.ID != "" {
+			continue
+		}
+		matched[i].ID = NewID()
+		for _, l := range local {
+			if l.Description == s.Description && l.Command == s.Command {
+				matched[i].ID = l.ID
+				break
+			}
+		}
+	}
+	return matched
+}
+
+// adoptRemoteIDs returns a copy of local where snippets added on both sides since base
+// with the same description and command take the ID of the remote snippet. Machines
+// which gave the same snippet different IDs, such as when IDs were backfilled on each of
+// them before their first sync, then keep one snippet instead of both.
+func adoptRemoteIDs(base, local, remote []SnippetInfo) []SnippetInfo {
+	adopted := make([]SnippetInfo, len(local))
+	copy(adopted, local)
+
+	baseByID := indexByID(base)
+	localByID := indexByID(local)
+	remoteByID := indexByID(remote)
+	taken := map[string]bool{}
+	for i, l := range adopted {
+		if baseByID[l.ID] != nil || remoteByID[l.ID] != nil {
+			continue
+		}
+		for _, r := range remote {
+			if baseByID[r.ID] == nil && localByID[r.ID] == nil && !taken[r.ID] &&
+				r.Description == l.Description && r.Command == l.Command {
+				adopted[i].ID = r.ID
+				taken[r.ID] = true
+				break
+			}
+		}
+	}
+	return adopted
+}
+
+func indexByID(snippets []SnippetInfo) map[string]*SnippetInfo {
+	index := map[string]*SnippetInfo{}
+	for i := range snippets {
+		index[snippets[i].ID] = &snippets[i]
+	}
+	return index
+}
+
+// sameContent reports whether two versions of a snippet only differ
+// in where they are stored and when they were updated or used
+func sameContent(a, b SnippetInfo) bool {
+	return reflect.DeepEqual(content(a), content(b))
+}
+
+func content(s SnippetInfo) SnippetInfo {
+	s.Filename = ""
+	s.CreatedAt = s.CreatedAt.UTC()
+	s.UpdatedAt = time.Time{}
+	s.LastUsedAt = time.Time{}
+	if len(s.Tag) == 0 {
+		s.Tag = nil
+	}
+	if len(s.Params) == 0 {
+		s.Params = nil
+	}
+	return s
+}
+
+// mergeTimestamps returns s with the latest update and use of s and other
+func mergeTimestamps(s, other SnippetInfo) SnippetInfo {
+	if other.UpdatedAt.After(s.UpdatedAt) {
+		s.UpdatedAt = other.UpdatedAt
+	}
+	if other.LastUsedAt.After(s.LastUsedAt) {
+		s.LastUsedAt = other.LastUsedAt
+	}
+	return s
+}
+
+// Kinds of changes reported by Diff
+const (
+	Added   = "+"
+	Removed = "-"
+	Changed = "~"
+)
+
+// Change is a snippet added, removed or changed between two versions of a snippet file
+type Change struct {
+	Kind    string
+	Snippet SnippetInfo
+}
+
+// Diff returns the snippets added, removed or changed from one version of a snippet file to another.
+// Snippets without an ID are matched like in Merge.
+func Diff(from, to []SnippetInfo) (changes []Change) {
+	from = matchIDs(from, to)
+	fromByID := indexByID(from)
+	toByID := indexByID(to)
+
+	for _, s := range to {
+		if f, ok := fromByID[s.ID]; !ok {
+			changes = append(changes, Change{Kind: Added, Snippet: s})
+		} else if !sameContent(*f, s) {
+			changes = append(changes, Change{Kind: Changed, Snippet: s})
+		}
+	}
+	for _, s := range from {
+		if _, ok := toByID[s.ID]; !ok {
+			changes = append(changes, Change{Kind: Removed, Snippet: s})
+		}
+	}
+	return changes
+}
@@ -0,0 +1,159 @@
+package snippet
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMerge(t *testing.T) {
+	ls := SnippetInfo{ID: "1", Description: "list", Command: "ls"}
+	lsL := SnippetInfo{ID: "1", Description: "list", Command: "ls -l"}
+	lsA := SnippetInfo{ID: "1", Description: "list", Command: "ls -a"}
+	ps := SnippetInfo{ID: "2", Description: "processes", Command: "ps"}
+	df := SnippetInfo{ID: "3", Description: "disk", Command: "df -h"}
+
+	tests := []struct {
+		name      string
+		base      []SnippetInfo
+		local     []SnippetInfo
+		remote    []SnippetInfo
+		merged    []SnippetInfo
+		conflicts []Conflict
+	}{
+		{
+			name:   "additions on both sides",
+			base:   []SnippetInfo{ls},
+			local:  []SnippetInfo{ls, ps},
+			remote: []SnippetInfo{ls, df},
+			merged: []SnippetInfo{ls, ps, df},
+		},
+		{
+			name:   "local edit and remote deletion of different snippets",
+			base:   []SnippetInfo{ls, ps},
+			local:  []SnippetInfo{lsL, ps},
+			remote: []SnippetInfo{ls},
+			merged: []SnippetInfo{lsL},
+		},
+		{
+			name:   "remote edit",
+			base:   []SnippetInfo{ls},
+			local:  []SnippetInfo{ls},
+			remote: []SnippetInfo{lsA},
+			merged: []SnippetInfo{lsA},
+		},
+		{
+			name:   "same edit on both sides",
+			base:   []SnippetInfo{ls},
+			local:  []SnippetInfo{lsL},
+			remote: []SnippetInfo{lsL},
+			merged: []SnippetInfo{lsL},
+		},
+		{
+			name:      "different edits",
+			base:      []SnippetInfo{ls, ps},
+			local:     []SnippetInfo{lsL, ps},
+			remote:    []SnippetInfo{lsA, ps},
+			merged:    []SnippetInfo{ps},
+			conflicts: []Conflict{{Base: &ls, Local: &lsL, Remote: &lsA}},
+		},
+		{
+			name:      "local deletion of a remotely edited snippet",
+			base:      []SnippetInfo{ls},
+			local:     nil,
+			remote:    []SnippetInfo{lsA},
+			conflicts: []Conflict{{Base: &ls, Remote: &lsA}},
+		},
+		{
+			name:   "remote deletion of an unchanged snippet",
+			base:   []SnippetInfo{ls, ps},
+			local:  []SnippetInfo{ls, ps},
+			remote: []SnippetInfo{ps},
+			merged: []SnippetInfo{ps},
+		},
+		{
+			name:   "first sync keeps both sides",
+			base:   nil,
+			local:  []SnippetInfo{ls},
+			remote: []SnippetInfo{ls, ps},
+			merged: []SnippetInfo{ls, ps},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged, conflicts := Merge(tt.base, tt.local, tt.remote)
+			assert.Equal(t, tt.merged, merged)
+			assert.Equal(t, tt.conflicts, conflicts)
+		})
+	}
+}
+
+func TestMergeMatchesSnippetsWithoutID(t *testing.T) {
+	local := []SnippetInfo{{ID: "1", Description: "list", Command: "ls"}}
+	remote := []SnippetInfo{{Description: "list", Command: "ls"}}
+
+	merged, conflicts := Merge(nil, local, remote)
+	assert.Empty(t, conflicts)
+	assert.Equal(t, local, merged)
+}
+
+func TestMergeMatchesSnippetsAddedWithDifferentIDs(t *testing.T) {
+	local := []SnippetInfo{{ID: "a", Description: "list", Command: "ls"}, {ID: "b", Description: "disk", Command: "df"}}
+	remote := []SnippetInfo{{ID: "x", Description: "list", Command: "ls"}, {ID: "y", Description: "processes", Command: "ps"}}
+
+	merged, conflicts := Merge(nil, local, remote)
+	assert.Empty(t, conflicts)
+	assert.Equal(t, []SnippetInfo{
+		{ID: "x", Description: "list", Command: "ls"},
+		{ID: "b", Description: "disk", Command: "df"},
+		{ID: "y", Description: "processes", Command: "ps"},
+	}, merged)
+}
+
+func TestMergeKeepsLatestTimestamps(t *testing.T) {
+	older := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	base := []SnippetInfo{{ID: "1", Command: "ls"}}
+	local := []SnippetInfo{{ID: "1", Command: "ls", Filename: "local.toml", LastUsedAt: newer}}
+	remote := []SnippetInfo{{ID: "1", Command: "ls -l", UpdatedAt: newer, LastUsedAt: older}}
+
+	merged, conflicts := Merge(base, local, remote)
+	assert.Empty(t, conflicts)
+	assert.Equal(t, []SnippetInfo{
+		{ID: "1", Command: "ls -l", Filename: "local.toml", UpdatedAt: newer, LastUsedAt: newer},
+	}, merged)
+}
+
+func TestParse(t *testing.T) {
+	snippets, err := Parse("[[Snippets]]\n  id = \"1\"\n  command = \"ls\"\n")
+	assert.NoError(t, err)
+	assert.Equal(t, []SnippetInfo{{ID: "1", Command: "ls"}}, snippets)
+
+	_, err = Parse("[[Snippets")
+	assert.Error(t, err)
+}
+
+func TestDiff(t *testing.T) {
+	ls := SnippetInfo{ID: "1", Description: "list", Command: "ls"}
+	lsL := SnippetInfo{ID: "1", Description: "list", Command: "ls -l"}
+	ps := SnippetInfo{ID: "2", Description: "processes", Command: "ps"}
+	df := SnippetInfo{ID: "3", Description: "disk", Command: "df -h"}
+	usedPs := ps
+	usedPs.LastUsedAt = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	changes := Diff([]SnippetInfo{ls, ps}, []SnippetInfo{lsL, usedPs, df})
+	assert.Equal(t, []Change{
+		{Kind: Changed, Snippet: lsL},
+		{Kind: Added, Snippet: df},
+	}, changes)
+
+	changes = Diff([]SnippetInfo{ls, ps}, []SnippetInfo{ps})
+	assert.Equal(t, []Change{{Kind: Removed, Snippet: ls}}, changes)
+
+	// Snippets without ID are matched by description and command
+	changes = Diff([]SnippetInfo{{Description: "list", Command: "ls"}}, []SnippetInfo{ls})
+	assert.Empty(t, changes)
+}
@@ -0,0 +1,88 @@
+package snippet
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Parameter types which can be declared in a ParamSpec
+const (
+	ParamTypeString = "string"
+	ParamTypeInt    = "int"
+	ParamTypeBool   = "bool"
+	ParamTypePath   = "path"
+	ParamTypeEnum   = "enum"
+	ParamTypeRegex  = "regex"
+)
+
+// ParamSpec declares how the value of a <param> in the command is validated
+type ParamSpec struct {
+	Type        string   `toml:"type,omitempty" yaml:"type,omitempty" json:"type,omitempty"`
+	Required    bool     `toml:"required,omitempty" yaml:"required,omitempty" json:"required,omitempty"`
+	Description string   `toml:"description,omitempty" yaml:"description,omitempty" json:"description,omitempty"`
+	Values      []string `toml:"values,omitempty" yaml:"values,omitempty" json:"values,omitempty"`             // allowed values of an enum
+	Pattern     string   `toml:"pattern,omitempty" yaml:"pattern,omitempty" json:"pattern,omitempty"`          // regular expression a regex value must match
+	Suggest     string   `toml:"suggest,omitempty" yaml:"suggest,omitempty" json:"suggest,omitempty"`          // command whose output lines are offered as values
+	NoHistory   bool     `toml:"no_history,omitempty" yaml:"no_history,omitempty" json:"no_history,omitempty"` // values are never remembered, e.g. passwords
+}
+
+// Validate returns an error describing why the value is not valid for the parameter
+func (spec ParamSpec) Validate(value string) error {
+	if value == "" {
+		if spec.Required {
+			return errors.New("value is required")
+		}
+		return nil
+	}
+
+	switch spec.Type {
+	case "", ParamTypeString:
+		return nil
+	case ParamTypeInt:
+		if _, err := strconv.Atoi(value); err != nil {
+			return errors.New("must be an integer")
+		}
+	case ParamTypeBool:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return errors.New("must be true or false")
+		}
+	case ParamTypePath:
+		if _, err := os.Stat(expandHome(value)); err != nil {
+			return errors.New("must be an existing file or directory")
+		}
+	case ParamTypeEnum:
+		if !slices.Contains(spec.Values, value) {
+			return fmt.Errorf("must be one of %s", strings.Join(spec.Values, ", "))
+		}
+	case ParamTypeRegex:
+		r, err := regexp.Compile(spec.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern %q", spec.Pattern)
+		}
+		if !r.MatchString(value) {
+			return fmt.Errorf("must match %s", spec.Pattern)
+		}
+	default:
+		return fmt.Errorf("unknown parameter type %q", spec.Type)
+	}
+	return nil
+}
+
+// expandHome expands a leading ~ to the home directory, other paths are left as they are
+func expandHome(p string) string {
+	if p != "~" && !strings.HasPrefix(p, "~/") {
+		return p
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return p
+	}
+	return filepath.Join(home, p[1:])
+}
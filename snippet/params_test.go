@@ -0,0 +1,43 @@
+package snippet
+
+import (
+	"os"
+	"testing"
+)
+
+func TestParamSpecValidate(t *testing.T) {
+	tempDir, _ := os.MkdirTemp("", "testdata")
+	defer os.RemoveAll(tempDir)
+
+	tests := []struct {
+		name    string
+		spec    ParamSpec
+		value   string
+		wantErr bool
+	}{
+		{name: "success - untyped", spec: ParamSpec{}, value: "anything"},
+		{name: "success - optional empty value", spec: ParamSpec{Type: ParamTypeInt}, value: ""},
+		{name: "error - required empty value", spec: ParamSpec{Required: true}, value: "", wantErr: true},
+		{name: "success - int", spec: ParamSpec{Type: ParamTypeInt}, value: "8080"},
+		{name: "error - int", spec: ParamSpec{Type: ParamTypeInt}, value: "http", wantErr: true},
+		{name: "success - bool", spec: ParamSpec{Type: ParamTypeBool}, value: "true"},
+		{name: "error - bool", spec: ParamSpec{Type: ParamTypeBool}, value: "maybe", wantErr: true},
+		{name: "success - path", spec: ParamSpec{Type: ParamTypePath}, value: tempDir},
+		{name: "error - path", spec: ParamSpec{Type: ParamTypePath}, value: tempDir + "/missing", wantErr: true},
+		{name: "success - enum", spec: ParamSpec{Type: ParamTypeEnum, Values: []string{"dev", "prod"}}, value: "prod"},
+		{name: "error - enum", spec: ParamSpec{Type: ParamTypeEnum, Values: []string{"dev", "prod"}}, value: "staging", wantErr: true},
+		{name: "success - regex", spec: ParamSpec{Type: ParamTypeRegex, Pattern: `^v\d+$`}, value: "v12"},
+		{name: "error - regex", spec: ParamSpec{Type: ParamTypeRegex, Pattern: `^v\d+$`}, value: "12", wantErr: true},
+		{name: "error - invalid regex", spec: ParamSpec{Type: ParamTypeRegex, Pattern: `(`}, value: "12", wantErr: true},
+		{name: "error - unknown type", spec: ParamSpec{Type: "float"}, value: "1.5", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.spec.Validate(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Expected error %v, but got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
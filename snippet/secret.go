@@ -0,0 +1,138 @@
+package snippet
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/knqyf263/pet/config"
+	"github.com/pelletier/go-toml"
+	"gopkg.in/alessio/shellescape.v1"
+)
+
+// secretRegex matches a reference to a secret, ex. {{secret:github_token}}
+var secretRegex = regexp.MustCompile(`\{\{secret:([A-Za-z0-9_.\-/]+)\}\}`)
+
+var (
+	secretsMu sync.Mutex
+	secrets   = map[string]string{} // resolved in this process, by name
+)
+
+// ResolveSecrets replaces the secrets referenced by the command with their values.
+// A secret is looked up in the environment variable of the same name, then in the
+// secrets file and finally with the helper command. The values are quoted to fit where
+// the reference stands, bare or inside single or double quotes, so that the shell reads
+// a secret as it is and never runs it as a command.
+// Only the command to be run or copied holds the values, the snippets keep the references.
+func ResolveSecrets(command string) (string, error) {
+	var resolved strings.Builder
+	last := 0
+	for _, m := range secretRegex.FindAllStringSubmatchIndex(command, -1) {
+		value, err := lookupSecret(command[m[2]:m[3]])
+		if err != nil {
+			return "", err
+		}
+		resolved.WriteString(command[last:m[0]])
+		resolved.WriteString(quoteSecret(value, shellQuoting(command[:m[0]])))
+		last = m[1]
+	}
+	resolved.WriteString(command[last:])
+	return resolved.String(), nil
+}
+
+// doubleQuoteEscaper escapes the characters which keep their meaning inside double quotes
+var doubleQuoteEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`, "`", "\\`")
+
+// quoteSecret quotes a secret for the quoting it is inserted in
+func quoteSecret(value string, quoting byte) string {
+	switch quoting {
+	case '\'':
+		return strings.ReplaceAll(value, `'`, `'\''`)
+	case '"':
+		return doubleQuoteEscaper.Replace(value)
+	default:
+		return shellescape.Quote(value)
+	}
+}
+
+// shellQuoting returns the quote left open at the end of a shell command, or 0 if none is
+func shellQuoting(command string) byte {
+	var quote byte
+	for i := 0; i < len(command); i++ {
+		switch c := command[i]; {
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+			}
+		case c == '\\':
+			i++ // the next character is escaped
+		case quote == '"':
+			if c == '"' {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		}
+	}
+	return quote
+}
+
+// lookupSecret returns the value of a secret
+func lookupSecret(name string) (string, error) {
+	if value, ok := os.LookupEnv(name); ok {
+		return value, nil
+	}
+
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	if value, ok := secrets[name]; ok {
+		return value, nil
+	}
+
+	values, err := loadSecretsFile()
+	if err != nil {
+		return "", err
+	}
+	value, ok := values[name]
+
+	if helper := config.Conf.Secret.Helper; !ok && helper != "" {
+		cmd := shellCommand(helper + " " + shellescape.Quote(name))
+		cmd.Env = append(os.Environ(), "PET_SECRET_NAME="+name)
+		cmd.Stderr = os.Stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("failed to get secret %s from the helper. %v", name, err)
+		}
+		value, ok = strings.TrimRight(string(out), "\r\n"), true
+	}
+
+	if !ok {
+		return "", fmt.Errorf(`secret not found: %s.
+Export $%s, write it in the secrets file or set helper in [Secret] in config file (pet configure).`, name, name)
+	}
+	secrets[name] = value
+	return value, nil
+}
+
+// loadSecretsFile loads the name = "value" pairs of the secrets file, which may be encrypted
+func loadSecretsFile() (map[string]string, error) {
+	file, err := localFile(config.Conf.Secret.File, "secrets.toml")
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ReadFile(file.Get())
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to load secrets file. %v", err)
+	}
+
+	var values map[string]string
+	if err := toml.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("failed to parse secrets file. %v", err)
+	}
+	return values, nil
+}
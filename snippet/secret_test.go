@@ -0,0 +1,111 @@
+//go:build !windows
+
+package snippet
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/knqyf263/pet/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupSecrets(t *testing.T) string {
+	orig := config.Conf
+	t.Cleanup(func() { config.Conf = orig })
+	t.Cleanup(func() { secrets = map[string]string{} })
+
+	dir := t.TempDir()
+	config.Conf = config.Config{}
+	config.Conf.Secret.File = filepath.Join(dir, "secrets.toml")
+	return dir
+}
+
+func TestResolveSecrets(t *testing.T) {
+	dir := setupSecrets(t)
+	t.Setenv("PET_TEST_TOKEN", "from-env")
+	require.NoError(t, os.WriteFile(config.Conf.Secret.File, []byte("db_password = \"from-file\"\n"), 0600))
+	helper := filepath.Join(dir, "helper")
+	require.NoError(t, os.WriteFile(helper, []byte("#!/bin/sh\necho call >> "+filepath.Join(dir, "calls")+"\necho helper-$1\n"), 0700))
+	config.Conf.Secret.Helper = helper
+
+	resolved, err := ResolveSecrets("curl -u admin:{{secret:PET_TEST_TOKEN}} && psql -W {{secret:db_password}} {{secret:team/api}}")
+	require.NoError(t, err)
+	assert.Equal(t, "curl -u admin:from-env && psql -W from-file helper-team/api", resolved)
+
+	// Each secret is asked from the helper once
+	resolved, err = ResolveSecrets("{{secret:team/api}}")
+	require.NoError(t, err)
+	assert.Equal(t, "helper-team/api", resolved)
+	assert.Equal(t, "call\n", readTestFile(t, filepath.Join(dir, "calls")))
+
+	// Commands without references are left alone
+	resolved, err = ResolveSecrets("echo {{not a secret}} <param>")
+	require.NoError(t, err)
+	assert.Equal(t, "echo {{not a secret}} <param>", resolved)
+}
+
+func TestResolveSecretsQuotesValues(t *testing.T) {
+	setupSecrets(t)
+	value := "p@ss word'; rm -rf ~ $(id) `id` && echo \"*\""
+	t.Setenv("PET_TEST_TOKEN", value)
+
+	resolved, err := ResolveSecrets("printf %s {{secret:PET_TEST_TOKEN}}")
+	require.NoError(t, err)
+	assert.Equal(t, `printf %s 'p@ss word'"'"'; rm -rf ~ $(id) `+"`id`"+` && echo "*"'`, resolved)
+
+	// The shell sees the secret as a single argument, as it is
+	out, err := shellCommand(resolved).Output()
+	require.NoError(t, err)
+	assert.Equal(t, value, string(out))
+}
+
+func TestResolveSecretsInQuotes(t *testing.T) {
+	setupSecrets(t)
+	value := `p@ss "word" 'x' $def $(id) ` + "`id`" + ` \ *`
+	t.Setenv("PET_TEST_TOKEN", value)
+
+	tests := []struct {
+		command string
+		output  string
+	}{
+		{command: `printf %s "me:{{secret:PET_TEST_TOKEN}}"`, output: "me:" + value},
+		{command: `printf %s "Bearer {{secret:PET_TEST_TOKEN}}" "{{secret:PET_TEST_TOKEN}}"`, output: "Bearer " + value + value},
+		{command: `printf %s 'me:{{secret:PET_TEST_TOKEN}}'`, output: "me:" + value},
+		{command: `printf %s "it's \"{{secret:PET_TEST_TOKEN}}\"" '"'{{secret:PET_TEST_TOKEN}}`, output: `it's "` + value + `""` + value},
+	}
+	for _, tt := range tests {
+		resolved, err := ResolveSecrets(tt.command)
+		require.NoError(t, err)
+
+		out, err := shellCommand(resolved).Output()
+		require.NoError(t, err, resolved)
+		assert.Equal(t, tt.output, string(out), tt.command)
+	}
+}
+
+func TestResolveSecretsNotFound(t *testing.T) {
+	setupSecrets(t)
+
+	_, err := ResolveSecrets("echo {{secret:missing}}")
+	assert.ErrorContains(t, err, "secret not found: missing")
+
+	config.Conf.Secret.Helper = "exit 1"
+	_, err = ResolveSecrets("echo {{secret:missing}}")
+	assert.ErrorContains(t, err, "failed to get secret missing from the helper")
+}
+
+func TestResolveSecretsFromEncryptedFile(t *testing.T) {
+	setupSecrets(t)
+	setupEncryption(t, "correct horse")
+
+	encrypted, err := Encrypt([]byte("token = \"s3cr3t\"\n"))
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(config.Conf.Secret.File, encrypted, 0600))
+
+	resolved, err := ResolveSecrets("echo {{secret:token}}")
+	require.NoError(t, err)
+	assert.Equal(t, "echo s3cr3t", resolved)
+}
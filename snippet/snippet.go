@@ -3,9 +3,12 @@ package snippet
 import (
 	"bytes"
 	"fmt"
+	"maps"
 	"os"
+	"reflect"
 	"slices"
 	"sort"
+	"time"
 
 	"github.com/knqyf263/pet/config"
 	"github.com/knqyf263/pet/path"
@@ -14,19 +17,37 @@ import (
 
 type Snippets struct {
 	Snippets []SnippetInfo
+
+	// files whose snippets were all removed, they are emptied on Save
+	emptied []string
 }
 
 type SnippetInfo struct {
 	Filename    string `toml:"-"`
+	ID          string `toml:"id,omitempty"`
 	Description string
 	Command     string `toml:"command,multiline"`
 	Tag         []string
 	Output      string
+	Params      map[string]ParamSpec `toml:"params,omitempty"`
+	CreatedAt   time.Time            `toml:"created_at,omitempty"`
+	UpdatedAt   time.Time            `toml:"updated_at,omitempty"`
+	LastUsedAt  time.Time            `toml:"last_used_at,omitempty"`
 }
 
 // Loads snippets from the main snippet file and all snippet
 // files in snippet directories if present
 func (snippets *Snippets) Load(includeDirs bool) error {
+	return snippets.load(includeDirs, true)
+}
+
+// LoadWithoutSaving loads snippets like Load, but leaves the IDs it backfills unsaved,
+// for previews which must not change any file
+func (snippets *Snippets) LoadWithoutSaving(includeDirs bool) error {
+	return snippets.load(includeDirs, false)
+}
+
+func (snippets *Snippets) load(includeDirs bool, saveIDs bool) error {
 	// Create a list of snippet files to load snippets from
 	var snippetFiles []string
 
@@ -62,26 +83,31 @@ if you only want to provide snippetdirs instead`,
 					return fmt.Errorf("snippet directory not found. %s", dir)
 				}
 			}
-			snippetFiles = append(snippetFiles, getFiles(dir)...)
+			snippetFiles = append(snippetFiles, GetFiles(dir)...)
 		}
 	}
 
 	// Read files and load snippets
+	seen := map[string]bool{}
 	for _, file := range snippetFiles {
 		absFile, err := path.NewAbsolutePath(file)
 		if err != nil {
 			return err
 		}
 
-		f, err := os.ReadFile(absFile.Get())
+		tmp, err := readSnippets(absFile, file)
 		if err != nil {
-			return fmt.Errorf("failed to load snippet file. %v", err)
+			return err
 		}
 
-		tmp := Snippets{}
-		err = toml.Unmarshal(f, &tmp)
-		if err != nil {
-			return fmt.Errorf("failed to parse snippet file. %v", err)
+		// Backfill IDs of snippets created before IDs existed, or copied along with
+		// the ID of another snippet, and persist them so that they stay stable across runs
+		seenBefore := maps.Clone(seen)
+		if tmp.assignIDs(seen) && saveIDs && !IsReadOnly(file) {
+			seen = seenBefore
+			if tmp, err = backfillIDs(absFile, file, seen); err != nil {
+				return err
+			}
 		}
 
 		for _, snippet := range tmp.Snippets {
@@ -94,10 +120,55 @@ if you only want to provide snippetdirs instead`,
 	return nil
 }
 
+// readSnippets reads the snippets of a snippet file
+func readSnippets(absFile path.AbsolutePath, file string) (Snippets, error) {
+	f, err := ReadFile(absFile.Get())
+	if err != nil {
+		return Snippets{}, fmt.Errorf("failed to load snippet file. %v", err)
+	}
+
+	snippets := Snippets{}
+	if snippets.Snippets, err = FormatOf(file).Decode(f); err != nil {
+		return Snippets{}, fmt.Errorf("failed to parse snippet file. %v", err)
+	}
+	return snippets, nil
+}
+
+// backfillIDs saves the IDs backfilled in a snippet file. Files are written by atomic renames,
+// so reading needs no lock, but the file is read again under the lock before it is saved,
+// so that snippets another pet saved since are kept.
+func backfillIDs(absFile path.AbsolutePath, file string, seen map[string]bool) (Snippets, error) {
+	unlock, err := Lock()
+	if err != nil {
+		return Snippets{}, err
+	}
+	defer unlock()
+
+	snippets, err := readSnippets(absFile, file)
+	if err != nil {
+		return Snippets{}, err
+	}
+	if snippets.assignIDs(seen) {
+		if err := saveFile(absFile, snippets.Snippets); err != nil {
+			return Snippets{}, err
+		}
+	}
+	return snippets, nil
+}
+
 // Save saves the snippets to toml file.
 func (snippets *Snippets) Save() error {
+	unlock, err := Lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	snippetFiles := make(map[string][]SnippetInfo)
 
+	// New snippets get their ID on first save
+	snippets.assignIDs(map[string]bool{})
+
 	// Need to construct a bunch of snippet files if we have multiple snippet files and then save them all
 	for _, snippet := range snippets.Snippets {
 		if snippet.Filename == "" {
@@ -107,29 +178,58 @@ func (snippets *Snippets) Save() error {
 		snippetFiles[snippet.Filename] = append(snippetFiles[snippet.Filename], snippet)
 	}
 
-	// Save all snippet files
+	// Files left without snippets still need to be written
+	for _, file := range snippets.emptied {
+		if _, ok := snippetFiles[file]; !ok {
+			snippetFiles[file] = nil
+		}
+	}
+	snippets.emptied = nil
+
+	// Nothing is written if a read-only file would change
 	for file, snippets := range snippetFiles {
-		absFilePath, err := path.NewAbsolutePath(file)
-		if err != nil {
-			return fmt.Errorf("failed to save snippet file. err: %s", err)
+		if !IsReadOnly(file) {
+			continue
 		}
+		if err := checkUnchanged(file, snippets); err != nil {
+			return err
+		}
+		delete(snippetFiles, file)
+	}
 
-		// Overwrite snippet file with snippets
-		f, err := os.Create(absFilePath.Get())
+	// Save all snippet files
+	for file, snippets := range snippetFiles {
+		absFilePath, err := path.NewAbsolutePath(file)
 		if err != nil {
 			return fmt.Errorf("failed to save snippet file. err: %s", err)
 		}
-		defer f.Close()
 
-		err = toml.NewEncoder(f).Encode(Snippets{Snippets: snippets})
-		if err != nil {
-			return fmt.Errorf("failed to encode snippets while saving snippet file. err: %s", err)
+		if err := saveFile(absFilePath, snippets); err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
 
+// saveFile overwrites a single snippet file with the given snippets, in the format of the file
+func saveFile(filePath path.AbsolutePath, snippets []SnippetInfo) error {
+	data, err := FormatOf(filePath.Get()).Encode(snippets)
+	if err != nil {
+		return fmt.Errorf("failed to encode snippets while saving snippet file. err: %s", err)
+	}
+
+	// Unchanged files are not rewritten, so that their backup stays the version before the last change
+	if hasContent(filePath.Get(), data) {
+		return nil
+	}
+
+	if err := WriteFile(filePath.Get(), data, 0666); err != nil {
+		return fmt.Errorf("failed to save snippet file. err: %s", err)
+	}
+	return RecordVersion(filePath.Get(), data, ReasonSave)
+}
+
 // ToString returns the contents of toml file.
 func (snippets *Snippets) ToString() (string, error) {
 	var buffer bytes.Buffer
@@ -142,6 +242,8 @@ func (snippets *Snippets) ToString() (string, error) {
 
 // Order snippets regarding SortBy option defined in config toml
 // Prefix "-" reverses the order, default is "recency", "+<expressions>" is the same as "<expression>"
+// "last_used", "created" and "updated" put the most recent snippets first
+// "frecency" puts the most frequently and recently used snippets first
 func (snippets *Snippets) Order() {
 	sortBy := config.Conf.General.SortBy
 	switch {
@@ -160,11 +262,36 @@ func (snippets *Snippets) Order() {
 	case sortBy == "-output":
 		sort.Sort(sort.Reverse(ByOutput(snippets.Snippets)))
 
+	case sortBy == "last_used" || sortBy == "+last_used":
+		snippets.orderByLastUsed(false)
+	case sortBy == "-last_used":
+		snippets.orderByLastUsed(true)
+
+	case sortBy == "created" || sortBy == "+created":
+		sort.Stable(ByCreated(snippets.Snippets))
+	case sortBy == "-created":
+		sort.Stable(sort.Reverse(ByCreated(snippets.Snippets)))
+
+	case sortBy == "updated" || sortBy == "+updated":
+		sort.Stable(ByUpdated(snippets.Snippets))
+	case sortBy == "-updated":
+		sort.Stable(sort.Reverse(ByUpdated(snippets.Snippets)))
+
+	case sortBy == "frecency" || sortBy == "+frecency":
+		snippets.orderByFrecency(false)
+	case sortBy == "-frecency":
+		snippets.orderByFrecency(true)
+
 	case sortBy == "-recency":
 		snippets.reverse()
 	}
 }
 
+// Now returns the current time as it is stored in snippet timestamps
+func Now() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
+}
+
 // FilterByTags filters snippets by tags.
 func (snippets *Snippets) FilterByTags(tags []string) (filteredSnippets []SnippetInfo) {
 	for _, snippet := range snippets.Snippets {
@@ -183,6 +310,83 @@ func (snippets *Snippets) FilterByTags(tags []string) (filteredSnippets []Snippe
 	return filteredSnippets
 }
 
+// FindByID returns the snippet with the given ID
+func (snippets *Snippets) FindByID(id string) (SnippetInfo, bool) {
+	for _, snippet := range snippets.Snippets {
+		if snippet.ID == id {
+			return snippet, true
+		}
+	}
+	return SnippetInfo{}, false
+}
+
+// Update replaces the snippet having the same ID as the given snippet
+// and reports whether such a snippet was found
+func (snippets *Snippets) Update(snippet SnippetInfo) bool {
+	for i, s := range snippets.Snippets {
+		if s.ID == snippet.ID {
+			snippets.Snippets[i] = snippet
+			return true
+		}
+	}
+	return false
+}
+
+// Equal reports whether two snippets are the same, however their files decoded
+// empty tags and params and the time zone of their times
+func (s SnippetInfo) Equal(other SnippetInfo) bool {
+	normalize := func(s SnippetInfo) SnippetInfo {
+		if len(s.Tag) == 0 {
+			s.Tag = nil
+		}
+		if len(s.Params) == 0 {
+			s.Params = nil
+		}
+		s.CreatedAt, s.UpdatedAt, s.LastUsedAt = s.CreatedAt.UTC(), s.UpdatedAt.UTC(), s.LastUsedAt.UTC()
+		return s
+	}
+	return reflect.DeepEqual(normalize(s), normalize(other))
+}
+
+// Remove removes the snippets with the given IDs and returns the removed snippets
+func (snippets *Snippets) Remove(ids ...string) (removed []SnippetInfo) {
+	var kept []SnippetInfo
+	for _, snippet := range snippets.Snippets {
+		if slices.Contains(ids, snippet.ID) {
+			removed = append(removed, snippet)
+		} else {
+			kept = append(kept, snippet)
+		}
+	}
+	snippets.Snippets = kept
+
+	// Remember the files that no longer have any snippet
+	for _, r := range removed {
+		filename := r.Filename
+		if filename == "" {
+			filename = config.Conf.General.SnippetFile
+		}
+		if !slices.ContainsFunc(kept, func(s SnippetInfo) bool { return s.Filename == filename }) {
+			snippets.emptied = append(snippets.emptied, filename)
+		}
+	}
+	return removed
+}
+
+// assignIDs gives an ID to every snippet that does not have one yet, or whose ID
+// is already in seen, and reports whether any snippet was changed.
+// The IDs of the snippets are added to seen.
+func (snippets *Snippets) assignIDs(seen map[string]bool) (changed bool) {
+	for i := range snippets.Snippets {
+		if snippets.Snippets[i].ID == "" || seen[snippets.Snippets[i].ID] {
+			snippets.Snippets[i].ID = NewID()
+			changed = true
+		}
+		seen[snippets.Snippets[i].ID] = true
+	}
+	return changed
+}
+
 func (snippets *Snippets) reverse() {
 	for i, j := 0, len(snippets.Snippets)-1; i < j; i, j = i+1, j-1 {
 		snippets.Snippets[i], snippets.Snippets[j] = snippets.Snippets[j], snippets.Snippets[i]
@@ -206,3 +410,15 @@ type ByOutput []SnippetInfo
 func (a ByOutput) Len() int           { return len(a) }
 func (a ByOutput) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
 func (a ByOutput) Less(i, j int) bool { return a[i].Output > a[j].Output }
+
+type ByCreated []SnippetInfo
+
+func (a ByCreated) Len() int           { return len(a) }
+func (a ByCreated) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a ByCreated) Less(i, j int) bool { return a[i].CreatedAt.After(a[j].CreatedAt) }
+
+type ByUpdated []SnippetInfo
+
+func (a ByUpdated) Len() int           { return len(a) }
+func (a ByUpdated) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a ByUpdated) Less(i, j int) bool { return a[i].UpdatedAt.After(a[j].UpdatedAt) }
@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/knqyf263/pet/config"
 	"github.com/pelletier/go-toml"
@@ -14,6 +15,7 @@ func createSnippets1(absFilePath string) *Snippets {
 	snippets := &Snippets{}
 	snippets.Snippets = []SnippetInfo{
 		{
+			ID:          "id-1",
 			Description: "Test snippet",
 			Command:     "echo 'Hello, World!'",
 			Tag:         []string{"test"},
@@ -21,6 +23,7 @@ func createSnippets1(absFilePath string) *Snippets {
 			Filename:    absFilePath,
 		},
 		{
+			ID:          "id-2",
 			Description: "Test snippet 2",
 			Command:     "echo 'Hello, World 2!'",
 			Tag:         []string{"test"},
@@ -35,6 +38,7 @@ func createSnippets2(absFilePath string) *Snippets {
 	snippets := &Snippets{}
 	snippets.Snippets = []SnippetInfo{
 		{
+			ID:          "id-3",
 			Description: "Test snippet 3",
 			Command:     "echo 'Hello, World 3!'",
 			Tag:         []string{"test"},
@@ -42,6 +46,7 @@ func createSnippets2(absFilePath string) *Snippets {
 			Filename:    absFilePath,
 		},
 		{
+			ID:          "id-4",
 			Description: "Test snippet 4",
 			Command:     "echo 'Hello, World 4!'",
 			Tag:         []string{"test"},
@@ -56,6 +61,7 @@ func createSnippets3(absFilePath string) *Snippets {
 	snippets := &Snippets{}
 	snippets.Snippets = []SnippetInfo{
 		{
+			ID:          "id-5",
 			Description: "Test snippet 5",
 			Command:     "echo 'Hello, World 5!'",
 			Tag:         []string{"test"},
@@ -63,6 +69,7 @@ func createSnippets3(absFilePath string) *Snippets {
 			Filename:    absFilePath,
 		},
 		{
+			ID:          "id-6",
 			Description: "Test snippet 6",
 			Command:     "echo 'Hello, World 6!'",
 			Tag:         []string{"test"},
@@ -172,6 +179,7 @@ func TestSave(t *testing.T) {
 
 	// Create a snippet
 	snippet := SnippetInfo{
+		ID:          "id-1",
 		Description: "Test snippet",
 		Command:     "echo 'Hello, World!'",
 		Tag:         []string{"test"},
@@ -205,6 +213,7 @@ func TestSave(t *testing.T) {
   Output = "Hello, World!"
   Tag = ["test"]
   command = "echo 'Hello, World!'"
+  id = "id-1"
 `
 	assert.Equal(t, want, string(data))
 }
@@ -257,12 +266,14 @@ func TestSaveWithMultipleSnippetFiles(t *testing.T) {
   Output = "Hello, World!"
   Tag = ["test"]
   command = "echo 'Hello, World!'"
+  id = "id-1"
 
 [[Snippets]]
   Description = "Test snippet 2"
   Output = "Hello, World 2!"
   Tag = ["test"]
   command = "echo 'Hello, World 2!'"
+  id = "id-2"
 `
 	assert.Equal(t, want, string(data))
 
@@ -276,12 +287,14 @@ func TestSaveWithMultipleSnippetFiles(t *testing.T) {
   Output = "Hello, World 3!"
   Tag = ["test"]
   command = "echo 'Hello, World 3!'"
+  id = "id-3"
 
 [[Snippets]]
   Description = "Test snippet 4"
   Output = "Hello, World 4!"
   Tag = ["test"]
   command = "echo 'Hello, World 4!'"
+  id = "id-4"
 `
 	assert.Equal(t, want, string(data))
 
@@ -294,12 +307,14 @@ func TestSaveWithMultipleSnippetFiles(t *testing.T) {
   Output = "Hello, World 5!"
   Tag = ["test"]
   command = "echo 'Hello, World 5!'"
+  id = "id-5"
 
 [[Snippets]]
   Description = "Test snippet 6"
   Output = "Hello, World 6!"
   Tag = ["test"]
   command = "echo 'Hello, World 6!'"
+  id = "id-6"
 `
 	assert.Equal(t, want, string(data))
 }
@@ -308,18 +323,21 @@ func TestFilterByTags(t *testing.T) {
 	snippets := &Snippets{
 		Snippets: []SnippetInfo{
 			{
+				ID:          "id-1",
 				Description: "Test snippet",
 				Command:     "echo 'Hello, World!'",
 				Tag:         []string{"test"},
 				Output:      "Hello, World!",
 			},
 			{
+				ID:          "id-2",
 				Description: "Test snippet 2",
 				Command:     "echo 'Hello, World 2!'",
 				Tag:         []string{"test", "test2"},
 				Output:      "Hello, World 2!",
 			},
 			{
+				ID:          "id-3",
 				Description: "Test snippet 3",
 				Command:     "echo 'Hello, World 3!'",
 				Tag:         []string{"test", "test3"},
@@ -341,3 +359,215 @@ func TestFilterByTags(t *testing.T) {
 	assert.Equal(t, "Test snippet 2", filteredSnippets[1].Description)
 	assert.Equal(t, "Test snippet 3", filteredSnippets[2].Description)
 }
+
+func TestLoadBackfillsIDs(t *testing.T) {
+	tempDir, _ := os.MkdirTemp("", "testdata")
+	defer os.RemoveAll(tempDir)
+
+	// Mock configuration
+	config.Conf.General.SnippetFile = filepath.Join(tempDir, "snippets.toml")
+	config.Conf.General.SnippetDirs = []string{}
+
+	// Create a snippet file written before IDs existed
+	err := os.WriteFile(config.Conf.General.SnippetFile, []byte(`
+[[Snippets]]
+  Description = "Test snippet"
+  command = "echo 'Hello, World!'"
+`), 0644)
+	assert.NoError(t, err)
+
+	snippets := &Snippets{}
+	err = snippets.Load(false)
+	assert.NoError(t, err)
+	assert.Len(t, snippets.Snippets, 1)
+	assert.Len(t, snippets.Snippets[0].ID, 26)
+
+	// The backfilled ID must be persisted and stay the same on the next load
+	reloaded := &Snippets{}
+	err = reloaded.Load(false)
+	assert.NoError(t, err)
+	assert.Equal(t, snippets.Snippets[0].ID, reloaded.Snippets[0].ID)
+}
+
+func TestLoadReissuesDuplicateIDs(t *testing.T) {
+	tempDir, _ := os.MkdirTemp("", "testdata")
+	defer os.RemoveAll(tempDir)
+
+	// Mock configuration
+	config.Conf.General.SnippetFile = filepath.Join(tempDir, "snippets.toml")
+	snippetDir := filepath.Join(tempDir, "snippets")
+	config.Conf.General.SnippetDirs = []string{snippetDir}
+	defer func() { config.Conf.General.SnippetDirs = []string{} }()
+
+	// A snippet copied along with its ID, in the same file and in another one
+	block := `
+[[Snippets]]
+  id = "01HZZZZZZZZZZZZZZZZZZZZZZZ"
+  Description = "Test snippet"
+  command = "echo 'Hello, World!'"
+`
+	err := os.WriteFile(config.Conf.General.SnippetFile, []byte(block+block), 0644)
+	assert.NoError(t, err)
+	assert.NoError(t, os.Mkdir(snippetDir, 0755))
+	err = os.WriteFile(filepath.Join(snippetDir, "copy.toml"), []byte(block), 0644)
+	assert.NoError(t, err)
+
+	snippets := &Snippets{}
+	err = snippets.Load(true)
+	assert.NoError(t, err)
+	assert.Len(t, snippets.Snippets, 3)
+
+	ids := map[string]string{}
+	for _, s := range snippets.Snippets {
+		ids[s.ID] = s.Filename
+	}
+	assert.Len(t, ids, 3)
+	// The first copy keeps its ID
+	assert.Equal(t, config.Conf.General.SnippetFile, ids["01HZZZZZZZZZZZZZZZZZZZZZZZ"])
+
+	// The new IDs are persisted
+	reloaded := &Snippets{}
+	err = reloaded.Load(true)
+	assert.NoError(t, err)
+	for _, s := range reloaded.Snippets {
+		assert.Contains(t, ids, s.ID)
+	}
+}
+
+func TestOrderByLastUseOnThisMachine(t *testing.T) {
+	tempDir, _ := os.MkdirTemp("", "testdata")
+	defer os.RemoveAll(tempDir)
+	defer func() { config.Conf.General.SortBy = "" }()
+
+	// Mock configuration
+	config.Conf.General.StateFile = filepath.Join(tempDir, "state.toml")
+	config.Conf.General.SortBy = "last_used"
+
+	older := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	snippets := &Snippets{
+		Snippets: []SnippetInfo{
+			{ID: "id-1", LastUsedAt: older},
+			{ID: "id-2"},
+			{ID: "id-3"},
+		},
+	}
+	assert.NoError(t, RecordUsage("id-3"))
+
+	snippets.Order()
+
+	var got []string
+	for _, s := range snippets.Snippets {
+		got = append(got, s.ID)
+	}
+	assert.Equal(t, []string{"id-3", "id-1", "id-2"}, got)
+}
+
+func TestSaveLeavesUnchangedFilesAlone(t *testing.T) {
+	tempDir, _ := os.MkdirTemp("", "testdata")
+	defer os.RemoveAll(tempDir)
+
+	// Mock configuration
+	config.Conf.General.SnippetFile = filepath.Join(tempDir, "snippets.toml")
+	config.Conf.General.SnippetDirs = []string{}
+
+	snippets := &Snippets{Snippets: []SnippetInfo{{ID: "id-1", Command: "ls"}}}
+	assert.NoError(t, snippets.Save())
+	snippets.Snippets[0].Command = "ls -l"
+	assert.NoError(t, snippets.Save())
+
+	// Saving the same snippets again keeps the backup of the last change
+	assert.NoError(t, snippets.Save())
+	backup, err := os.ReadFile(config.Conf.General.SnippetFile + ".bak")
+	assert.NoError(t, err)
+	assert.Contains(t, string(backup), `command = "ls"`)
+}
+
+func TestFindByID(t *testing.T) {
+	snippets := createSnippets1("")
+
+	snippet, ok := snippets.FindByID("id-2")
+	assert.True(t, ok)
+	assert.Equal(t, "Test snippet 2", snippet.Description)
+
+	_, ok = snippets.FindByID("unknown")
+	assert.False(t, ok)
+}
+
+func TestRemove(t *testing.T) {
+	tempDir, _ := os.MkdirTemp("", "testdata")
+	defer os.RemoveAll(tempDir)
+
+	// Mock configuration
+	config.Conf.General.SnippetFile = filepath.Join(tempDir, "snippets.toml")
+	otherFile := filepath.Join(tempDir, "other.toml")
+
+	snippets := createSnippets1(config.Conf.General.SnippetFile)
+	snippets.Snippets = append(snippets.Snippets, createSnippets2(otherFile).Snippets...)
+
+	removed := snippets.Remove("id-1", "id-3", "id-4")
+	assert.Len(t, removed, 3)
+	assert.Len(t, snippets.Snippets, 1)
+	assert.Equal(t, "id-2", snippets.Snippets[0].ID)
+
+	// A file that lost all its snippets is still written
+	err := snippets.Save()
+	assert.NoError(t, err)
+
+	data, err := os.ReadFile(otherFile)
+	assert.NoError(t, err)
+	assert.Empty(t, string(data))
+}
+
+func TestUpdate(t *testing.T) {
+	snippets := createSnippets1("")
+
+	updated := snippets.Update(SnippetInfo{ID: "id-2", Description: "Updated", Command: "echo updated"})
+	assert.True(t, updated)
+	assert.Equal(t, "Updated", snippets.Snippets[1].Description)
+	assert.Equal(t, "echo updated", snippets.Snippets[1].Command)
+	assert.Equal(t, "Test snippet", snippets.Snippets[0].Description)
+
+	updated = snippets.Update(SnippetInfo{ID: "unknown"})
+	assert.False(t, updated)
+	assert.Len(t, snippets.Snippets, 2)
+}
+
+func TestOrderByTimestamps(t *testing.T) {
+	defer func() { config.Conf.General.SortBy = "" }()
+
+	older := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		sortBy string
+		want   []string
+	}{
+		{sortBy: "last_used", want: []string{"id-2", "id-1", "id-3"}},
+		{sortBy: "-last_used", want: []string{"id-3", "id-1", "id-2"}},
+		{sortBy: "created", want: []string{"id-3", "id-2", "id-1"}},
+		{sortBy: "+created", want: []string{"id-3", "id-2", "id-1"}},
+		{sortBy: "updated", want: []string{"id-1", "id-2", "id-3"}},
+		{sortBy: "-updated", want: []string{"id-3", "id-2", "id-1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sortBy, func(t *testing.T) {
+			config.Conf.General.SortBy = tt.sortBy
+			snippets := &Snippets{
+				Snippets: []SnippetInfo{
+					{ID: "id-1", CreatedAt: older, UpdatedAt: newer, LastUsedAt: older},
+					{ID: "id-2", CreatedAt: newer, UpdatedAt: older, LastUsedAt: newer},
+					{ID: "id-3", CreatedAt: newer.AddDate(0, 1, 0)},
+				},
+			}
+
+			snippets.Order()
+
+			var got []string
+			for _, s := range snippets.Snippets {
+				got = append(got, s.ID)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
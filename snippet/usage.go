@@ -0,0 +1,187 @@
+package snippet
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/knqyf263/pet/config"
+	"github.com/knqyf263/pet/path"
+	"github.com/pelletier/go-toml"
+)
+
+// Usage is how often and how recently a snippet was used on this machine
+type Usage struct {
+	Count    int       `toml:"count"`
+	LastUsed time.Time `toml:"last_used"`
+}
+
+// UsageStats is the local usage of snippets keyed by snippet ID.
+// It is kept in the state file, apart from the snippet files, so it is never synced.
+type UsageStats struct {
+	Usage map[string]Usage `toml:"usage"`
+}
+
+// stateFile returns the path of the local state file
+func stateFile() (path.AbsolutePath, error) {
+	return localFile(config.Conf.General.StateFile, "state.toml")
+}
+
+// localFile returns the configured path of a local file,
+// or the path of defaultName in the config directory if none is configured
+func localFile(file string, defaultName string) (path.AbsolutePath, error) {
+	if file == "" {
+		dir, err := config.GetDefaultConfigDir()
+		if err != nil {
+			return nil, err
+		}
+		file = filepath.Join(dir, defaultName)
+	}
+	return path.NewAbsolutePath(file)
+}
+
+// LoadUsage loads the usage statistics from the state file
+func LoadUsage() (UsageStats, error) {
+	stats := UsageStats{Usage: map[string]Usage{}}
+
+	file, err := stateFile()
+	if err != nil {
+		return stats, err
+	}
+
+	f, err := os.ReadFile(file.Get())
+	if os.IsNotExist(err) {
+		return stats, nil
+	} else if err != nil {
+		return stats, fmt.Errorf("failed to load state file. %v", err)
+	}
+
+	if err := toml.Unmarshal(f, &stats); err != nil {
+		return stats, fmt.Errorf("failed to parse state file. %v", err)
+	}
+	if stats.Usage == nil {
+		stats.Usage = map[string]Usage{}
+	}
+	return stats, nil
+}
+
+// Save writes the usage statistics to the state file
+func (stats UsageStats) Save() error {
+	file, err := stateFile()
+	if err != nil {
+		return err
+	}
+
+	var buffer bytes.Buffer
+	if err := toml.NewEncoder(&buffer).Encode(stats); err != nil {
+		return fmt.Errorf("failed to encode state file. %v", err)
+	}
+	return os.WriteFile(file.Get(), buffer.Bytes(), 0600)
+}
+
+// Record counts one use of each snippet at the given time
+func (stats UsageStats) Record(t time.Time, ids ...string) {
+	for _, id := range ids {
+		usage := stats.Usage[id]
+		usage.Count++
+		usage.LastUsed = t
+		stats.Usage[id] = usage
+	}
+}
+
+// LastUsed returns when a snippet was last used on this machine, or when it was last used
+// according to the snippet file, which kept the time of the last use in older versions of pet
+func (stats UsageStats) LastUsed(s SnippetInfo) time.Time {
+	if usage, ok := stats.Usage[s.ID]; ok && usage.LastUsed.After(s.LastUsedAt) {
+		return usage.LastUsed
+	}
+	return s.LastUsedAt
+}
+
+// Frecency scores a snippet by combining its use count with how recently it was used
+func (stats UsageStats) Frecency(id string, now time.Time) float64 {
+	usage, ok := stats.Usage[id]
+	if !ok {
+		return 0
+	}
+
+	age := now.Sub(usage.LastUsed)
+	weight := 10.0
+	switch {
+	case age < 4*24*time.Hour:
+		weight = 100
+	case age < 14*24*time.Hour:
+		weight = 70
+	case age < 31*24*time.Hour:
+		weight = 50
+	case age < 90*24*time.Hour:
+		weight = 30
+	}
+	return float64(usage.Count) * weight
+}
+
+// RecordUsage records one use of each snippet in the state file
+func RecordUsage(ids ...string) error {
+	stats, err := LoadUsage()
+	if err != nil {
+		return err
+	}
+	stats.Record(Now(), ids...)
+	return stats.Save()
+}
+
+// ByFrecency sorts the most frequently and recently used snippets first
+type ByFrecency struct {
+	Snippets []SnippetInfo
+	Stats    UsageStats
+	Now      time.Time
+}
+
+func (a ByFrecency) Len() int      { return len(a.Snippets) }
+func (a ByFrecency) Swap(i, j int) { a.Snippets[i], a.Snippets[j] = a.Snippets[j], a.Snippets[i] }
+func (a ByFrecency) Less(i, j int) bool {
+	return a.Stats.Frecency(a.Snippets[i].ID, a.Now) > a.Stats.Frecency(a.Snippets[j].ID, a.Now)
+}
+
+func (snippets *Snippets) orderByFrecency(reverse bool) {
+	// Usage is only a hint for ordering, an unreadable state file keeps the file order
+	stats, err := LoadUsage()
+	if err != nil {
+		return
+	}
+
+	var s sort.Interface = ByFrecency{Snippets: snippets.Snippets, Stats: stats, Now: time.Now()}
+	if reverse {
+		s = sort.Reverse(s)
+	}
+	sort.Stable(s)
+}
+
+// ByLastUsed sorts the most recently used snippets first
+type ByLastUsed struct {
+	Snippets []SnippetInfo
+	Stats    UsageStats
+}
+
+func (a ByLastUsed) Len() int      { return len(a.Snippets) }
+func (a ByLastUsed) Swap(i, j int) { a.Snippets[i], a.Snippets[j] = a.Snippets[j], a.Snippets[i] }
+func (a ByLastUsed) Less(i, j int) bool {
+	return a.Stats.LastUsed(a.Snippets[i]).After(a.Stats.LastUsed(a.Snippets[j]))
+}
+
+func (snippets *Snippets) orderByLastUsed(reverse bool) {
+	// Without the state file, only the times kept in snippet files are known
+	stats, err := LoadUsage()
+	if err != nil {
+		stats = UsageStats{}
+	}
+
+	var s sort.Interface = ByLastUsed{Snippets: snippets.Snippets, Stats: stats}
+	if reverse {
+		s = sort.Reverse(s)
+	}
+	sort.Stable(s)
+}
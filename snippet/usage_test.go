@@ -0,0 +1,76 @@
+package snippet
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/knqyf263/pet/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordUsage(t *testing.T) {
+	tempDir, _ := os.MkdirTemp("", "testdata")
+	defer os.RemoveAll(tempDir)
+
+	// Mock configuration
+	config.Conf.General.StateFile = filepath.Join(tempDir, "state.toml")
+
+	// No state file yet
+	stats, err := LoadUsage()
+	assert.NoError(t, err)
+	assert.Empty(t, stats.Usage)
+
+	assert.NoError(t, RecordUsage("id-1", "id-2"))
+	assert.NoError(t, RecordUsage("id-1"))
+
+	stats, err = LoadUsage()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, stats.Usage["id-1"].Count)
+	assert.Equal(t, 1, stats.Usage["id-2"].Count)
+	assert.False(t, stats.Usage["id-1"].LastUsed.IsZero())
+}
+
+func TestFrecency(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	stats := UsageStats{Usage: map[string]Usage{
+		"recent": {Count: 2, LastUsed: now.Add(-time.Hour)},
+		"week":   {Count: 2, LastUsed: now.AddDate(0, 0, -7)},
+		"old":    {Count: 20, LastUsed: now.AddDate(-1, 0, 0)},
+	}}
+
+	assert.Equal(t, 200.0, stats.Frecency("recent", now))
+	assert.Equal(t, 140.0, stats.Frecency("week", now))
+	assert.Equal(t, 200.0, stats.Frecency("old", now))
+	assert.Equal(t, 0.0, stats.Frecency("unknown", now))
+}
+
+func TestOrderByFrecency(t *testing.T) {
+	tempDir, _ := os.MkdirTemp("", "testdata")
+	defer os.RemoveAll(tempDir)
+	defer func() { config.Conf.General.SortBy = "" }()
+
+	// Mock configuration
+	config.Conf.General.StateFile = filepath.Join(tempDir, "state.toml")
+
+	stats := UsageStats{Usage: map[string]Usage{}}
+	stats.Record(time.Now(), "id-3", "id-2", "id-3")
+	assert.NoError(t, stats.Save())
+
+	snippets := &Snippets{
+		Snippets: []SnippetInfo{{ID: "id-1"}, {ID: "id-2"}, {ID: "id-3"}},
+	}
+
+	config.Conf.General.SortBy = "frecency"
+	snippets.Order()
+	assert.Equal(t, "id-3", snippets.Snippets[0].ID)
+	assert.Equal(t, "id-2", snippets.Snippets[1].ID)
+	assert.Equal(t, "id-1", snippets.Snippets[2].ID)
+
+	config.Conf.General.SortBy = "-frecency"
+	snippets.Order()
+	assert.Equal(t, "id-1", snippets.Snippets[0].ID)
+	assert.Equal(t, "id-2", snippets.Snippets[1].ID)
+	assert.Equal(t, "id-3", snippets.Snippets[2].ID)
+}
@@ -1,18 +1,23 @@
 package snippet
 
 import (
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
-	"regexp"
+	"sync"
 
 	"github.com/knqyf263/pet/path"
 )
 
-var tomlRegEx = regexp.MustCompile(`^.+\.(toml)$`)
+var (
+	skippedMu sync.Mutex
+	skipped   = map[string]bool{} // files warned about in this process
+)
 
-// getFiles returns a list of files in the specified directory.
-func getFiles(dir string) (fileList []string) {
+// GetFiles returns a list of snippet files in the specified directory.
+// YAML and JSON files without a snippets document are skipped with a warning.
+func GetFiles(dir string) (fileList []string) {
 	absPath, err := path.NewAbsolutePath(dir)
 	if err != nil {
 		log.Fatal(err)
@@ -21,9 +26,14 @@ func getFiles(dir string) (fileList []string) {
 	err = filepath.Walk(
 		absPath.Get(),
 		func(p string, f os.FileInfo, err error) error {
-			if err == nil && tomlRegEx.MatchString(f.Name()) {
-				fileList = append(fileList, p)
+			if err != nil || !IsSnippetFile(f.Name()) {
+				return nil
+			}
+			if !isSnippetDocument(p) {
+				warnSkipped(p)
+				return nil
 			}
+			fileList = append(fileList, p)
 			return nil
 		},
 	)
@@ -34,3 +44,13 @@ func getFiles(dir string) (fileList []string) {
 
 	return fileList
 }
+
+// warnSkipped tells once that a file in a snippet directory is not a snippet file
+func warnSkipped(file string) {
+	skippedMu.Lock()
+	defer skippedMu.Unlock()
+	if !skipped[file] {
+		skipped[file] = true
+		fmt.Fprintf(os.Stderr, "Skipping %s, it holds no snippets\n", file)
+	}
+}
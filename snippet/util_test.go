@@ -8,7 +8,7 @@ import (
 	"github.com/go-test/deep"
 )
 
-func TestTomlRegEx(t *testing.T) {
+func TestIsSnippetFile(t *testing.T) {
 	tests := []struct {
 		name string
 		path string
@@ -18,8 +18,12 @@ func TestTomlRegEx(t *testing.T) {
 		{name: "match - absolute path", path: "/home/username/.config/pet/config.toml", want: true},
 		{name: "match - absolute path with home alias", path: "~/.config/pet/snippet2.toml", want: true},
 		{name: "match - relative path", path: "../../some/directory/best.toml", want: true},
-		{name: "mismatch - filename", path: "file.yaml", want: false},
-		{name: "mismatch - absolute path", path: "/home/username/.config/pet/config.json", want: false},
+		{name: "match - yaml", path: "file.yaml", want: true},
+		{name: "match - yml", path: "file.yml", want: true},
+		{name: "match - json", path: "/home/username/.config/pet/snippets.json", want: true},
+		{name: "match - markdown", path: "runbooks/deploy.md", want: true},
+		{name: "mismatch - filename", path: "file.txt", want: false},
+		{name: "mismatch - absolute path", path: "/home/username/.config/pet/config.ini", want: false},
 		{name: "mismatch - absolute path with home alias", path: "~/.config/pet/snippet2.xml", want: false},
 		{name: "mismatch - relative path", path: "../../some/directory/unrelated.html", want: false},
 		{name: "mismatch - extension with dot", path: ".toml", want: false},
@@ -31,7 +35,7 @@ func TestTomlRegEx(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := tomlRegEx.MatchString(tt.path)
+			got := IsSnippetFile(tt.path)
 
 			if got != tt.want {
 				t.Errorf("Expected result %v, but got %v", tt.want, got)
@@ -45,20 +49,26 @@ func TestGetFiles(t *testing.T) {
 	defer os.RemoveAll(testDataPath)
 
 	os.Create(filepath.Join(testDataPath, "01-snippet.toml"))
-	os.Create(filepath.Join(testDataPath, "02-unrelated.json"))
+	os.Create(filepath.Join(testDataPath, "02-unrelated.txt"))
 	os.Create(filepath.Join(testDataPath, "03-snippet.toml"))
 
 	subdir := filepath.Join(testDataPath, "04-subdir")
 	os.Mkdir(subdir, os.ModePerm)
 	os.Create(filepath.Join(subdir, "05-snippet.toml"))
-	os.Create(filepath.Join(subdir, "06-unrelated.yaml"))
+	os.Create(filepath.Join(subdir, "06-snippet.yaml"))
+	os.Create(filepath.Join(subdir, "07-unrelated.toml.bak"))
+	os.WriteFile(filepath.Join(subdir, "08-snippet.json"), []byte(`{"snippets": []}`), 0644)
+	os.WriteFile(filepath.Join(subdir, "09-unrelated.json"), []byte(`["not", "snippets"]`), 0644)
+	os.WriteFile(filepath.Join(subdir, "10-unrelated.yml"), []byte("services:\n  web:\n    image: nginx\n"), 0644)
 
-	t.Run("success - returns list of toml files in a directory", func(t *testing.T) {
-		got := getFiles(testDataPath)
+	t.Run("success - returns list of snippet files in a directory", func(t *testing.T) {
+		got := GetFiles(testDataPath)
 		want := []string{
 			filepath.Join(testDataPath, "01-snippet.toml"),
 			filepath.Join(testDataPath, "03-snippet.toml"),
 			filepath.Join(testDataPath, "04-subdir/05-snippet.toml"),
+			filepath.Join(testDataPath, "04-subdir/06-snippet.yaml"),
+			filepath.Join(testDataPath, "04-subdir/08-snippet.json"),
 		}
 
 		if diff := deep.Equal(want, got); diff != nil {
@@ -0,0 +1,130 @@
+package sync
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/knqyf263/pet/config"
+	"github.com/knqyf263/pet/path"
+	"github.com/pkg/errors"
+)
+
+// failureFile in the config directory holds the error of the last background sync which failed
+const failureFile = "sync.failed"
+
+// petExecutable returns the pet binary which runs background syncs
+var petExecutable = os.Executable
+
+// AutoSyncEnabled reports whether auto_sync is set for the configured backend
+func AutoSyncEnabled() bool {
+	switch config.Conf.General.Backend {
+	case "gitlab":
+		return config.Conf.GitLab.AutoSync
+	case "ghe":
+		return config.Conf.GHEGist.AutoSync
+	case "git":
+		return config.Conf.Git.AutoSync
+	case "s3":
+		return config.Conf.S3.AutoSync
+	case "webdav":
+		return config.Conf.WebDAV.AutoSync
+	case "gitea":
+		return config.Conf.Gitea.AutoSync
+	default:
+		return config.Conf.Gist.AutoSync
+	}
+}
+
+// AfterChange syncs the snippets after a command changed them, if auto_sync is enabled
+// for the configured backend. With backgroundsync, a separate pet process syncs
+// so that the command returns without waiting for the network.
+func AfterChange(configFile string) error {
+	if !AutoSyncEnabled() {
+		return nil
+	}
+
+	if config.Conf.General.BackgroundSync {
+		return syncInBackground(configFile)
+	}
+
+	filePath, err := path.NewAbsolutePath(config.Conf.General.SnippetFile)
+	if err != nil {
+		return err
+	}
+	return AutoSync(filePath)
+}
+
+// syncInBackground starts `pet sync --background` in a session of its own without waiting for it.
+// Its output, including conflicts it cannot resolve without a terminal, goes to sync.log in the config directory.
+func syncInBackground(configFile string) error {
+	exe, err := petExecutable()
+	if err != nil {
+		return errors.Wrap(err, "Failed to find the pet executable")
+	}
+
+	dir, err := config.GetDefaultConfigDir()
+	if err != nil {
+		return errors.Wrap(err, "Failed to get the default config directory")
+	}
+	log, err := os.OpenFile(filepath.Join(dir, "sync.log"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return errors.Wrap(err, "Failed to open the sync log")
+	}
+	defer log.Close()
+
+	args := []string{"sync", "--background"}
+	if configFile != "" {
+		args = append(args, "--config", configFile)
+	}
+	cmd := exec.Command(exe, args...)
+	cmd.Stdout = log
+	cmd.Stderr = log
+	detach(cmd)
+
+	if err := cmd.Start(); err != nil {
+		return errors.Wrap(err, "Failed to start the background sync")
+	}
+	return cmd.Process.Release()
+}
+
+// RecordBackgroundResult keeps the error of a failed background sync for ReportBackgroundFailure,
+// a successful one forgets the previous failure
+func RecordBackgroundResult(syncErr error) error {
+	dir, err := config.GetDefaultConfigDir()
+	if err != nil {
+		return errors.Wrap(err, "Failed to get the default config directory")
+	}
+	file := filepath.Join(dir, failureFile)
+
+	if syncErr == nil {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return errors.Wrap(err, "Failed to remove the last background sync failure")
+		}
+		return nil
+	}
+	msg := fmt.Sprintf("%s: %v\n", time.Now().Format(time.RFC3339), syncErr)
+	if err := os.WriteFile(file, []byte(msg), 0600); err != nil {
+		return errors.Wrap(err, "Failed to record the background sync failure")
+	}
+	return nil
+}
+
+// ReportBackgroundFailure tells about the last background sync which failed, once
+func ReportBackgroundFailure(w io.Writer) {
+	dir, err := config.GetDefaultConfigDir()
+	if err != nil {
+		return
+	}
+	file := filepath.Join(dir, failureFile)
+	msg, err := os.ReadFile(file)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "The last background sync failed at %s", msg)
+	fmt.Fprintf(w, "See %s for details, run `pet sync` to sync again.\n", filepath.Join(dir, "sync.log"))
+	os.Remove(file)
+}
@@ -0,0 +1,118 @@
+//go:build !windows
+
+package sync
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/knqyf263/pet/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAutoSyncEnabled(t *testing.T) {
+	orig := config.Conf
+	defer func() { config.Conf = orig }()
+
+	tests := []struct {
+		backend string
+		conf    func(*config.Config)
+	}{
+		{backend: "", conf: func(c *config.Config) { c.Gist.AutoSync = true }},
+		{backend: "gist", conf: func(c *config.Config) { c.Gist.AutoSync = true }},
+		{backend: "ghe", conf: func(c *config.Config) { c.GHEGist.AutoSync = true }},
+		{backend: "gitlab", conf: func(c *config.Config) { c.GitLab.AutoSync = true }},
+		{backend: "git", conf: func(c *config.Config) { c.Git.AutoSync = true }},
+		{backend: "s3", conf: func(c *config.Config) { c.S3.AutoSync = true }},
+		{backend: "webdav", conf: func(c *config.Config) { c.WebDAV.AutoSync = true }},
+		{backend: "gitea", conf: func(c *config.Config) { c.Gitea.AutoSync = true }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.backend, func(t *testing.T) {
+			config.Conf = config.Config{}
+			config.Conf.General.Backend = tt.backend
+			assert.False(t, AutoSyncEnabled())
+
+			tt.conf(&config.Conf)
+			assert.True(t, AutoSyncEnabled())
+		})
+	}
+
+	// auto_sync of another backend does not count
+	config.Conf = config.Config{}
+	config.Conf.General.Backend = "gitlab"
+	config.Conf.Gist.AutoSync = true
+	assert.False(t, AutoSyncEnabled())
+}
+
+func TestAfterChangeWithoutAutoSync(t *testing.T) {
+	orig := config.Conf
+	defer func() { config.Conf = orig }()
+	config.Conf = config.Config{}
+
+	// Nothing is synced, so no client is needed
+	assert.NoError(t, AfterChange(""))
+}
+
+func TestAfterChangeInBackground(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("PET_CONFIG_DIR", dir)
+
+	orig := config.Conf
+	defer func() { config.Conf = orig }()
+	config.Conf = config.Config{}
+	config.Conf.Gist.AutoSync = true
+	config.Conf.General.BackgroundSync = true
+
+	// A fake pet records how it was started
+	args := filepath.Join(dir, "args")
+	exe := filepath.Join(dir, "pet")
+	writeFile(t, exe, "#!/bin/sh\necho \"$@\" > "+args+".tmp && mv "+args+".tmp "+args+"\n")
+	require.NoError(t, os.Chmod(exe, 0755))
+
+	origExecutable := petExecutable
+	defer func() { petExecutable = origExecutable }()
+	petExecutable = func() (string, error) { return exe, nil }
+
+	require.NoError(t, AfterChange("/path/to/config.toml"))
+
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(args)
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, "sync --background --config /path/to/config.toml\n", readFile(t, args))
+	assert.FileExists(t, filepath.Join(dir, "sync.log"))
+}
+
+func TestReportBackgroundFailure(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("PET_CONFIG_DIR", dir)
+
+	var out bytes.Buffer
+	ReportBackgroundFailure(&out)
+	assert.Empty(t, out.String())
+
+	require.NoError(t, RecordBackgroundResult(errors.New("Failed to get Gist")))
+	ReportBackgroundFailure(&out)
+	assert.Contains(t, out.String(), "The last background sync failed at ")
+	assert.Contains(t, out.String(), ": Failed to get Gist\n")
+	assert.Contains(t, out.String(), filepath.Join(dir, "sync.log"))
+
+	// The failure is reported once
+	out.Reset()
+	ReportBackgroundFailure(&out)
+	assert.Empty(t, out.String())
+
+	// A successful sync forgets the failure before it
+	require.NoError(t, RecordBackgroundResult(errors.New("Failed to get Gist")))
+	require.NoError(t, RecordBackgroundResult(nil))
+	ReportBackgroundFailure(&out)
+	assert.Empty(t, out.String())
+	require.NoError(t, RecordBackgroundResult(nil))
+}
@@ -0,0 +1,14 @@
+//go:build !windows
+
+package sync
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// detach starts cmd in a session of its own, so that closing the terminal
+// or signalling the process group of pet does not kill it
+func detach(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+}
@@ -0,0 +1,16 @@
+//go:build windows
+
+package sync
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// detachedProcess starts a process without the console of its parent
+const detachedProcess = 0x00000008
+
+// detach starts cmd without the console of pet, so that closing it does not kill cmd
+func detach(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP | detachedProcess}
+}
@@ -0,0 +1,133 @@
+package sync
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/knqyf263/pet/config"
+	"github.com/knqyf263/pet/path"
+	"github.com/knqyf263/pet/snippet"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultRemoteFileName = "pet-snippet.toml"
+	// remotePathSeparator joins the directory name and the path of a snippet directory file
+	// in its remote name, as gists and GitLab snippets only hold flat file names
+	remotePathSeparator = "__"
+)
+
+// snippetDir is a snippet directory and the name its files are synced under
+type snippetDir struct {
+	path string
+	name string
+}
+
+// snippetDirs returns the snippet directories, named after their base names
+func snippetDirs() ([]snippetDir, error) {
+	var dirs []snippetDir
+	seen := map[string]string{}
+	for _, d := range config.Conf.General.SnippetDirs {
+		dir, err := path.NewAbsolutePath(d)
+		if err != nil {
+			return nil, err
+		}
+
+		name := filepath.Base(dir.Get())
+		if err := checkRemotePart(name); err != nil {
+			return nil, errors.Wrapf(err, "Cannot sync the snippet directory %s", dir.Get())
+		}
+		if other, ok := seen[name]; ok {
+			return nil, errors.Errorf("snippet directories %s and %s have the same name", other, dir.Get())
+		}
+		seen[name] = dir.Get()
+		dirs = append(dirs, snippetDir{path: dir.Get(), name: name})
+	}
+	return dirs, nil
+}
+
+// remoteName returns the name of a file of the snippet directory in the remote repository
+func (d snippetDir) remoteName(file string) (string, error) {
+	rel, err := filepath.Rel(d.path, file)
+	if err != nil {
+		return "", err
+	}
+	parts := append([]string{d.name}, strings.Split(filepath.ToSlash(rel), "/")...)
+	for _, part := range parts[1:] {
+		if err := checkRemotePart(part); err != nil {
+			return "", errors.Wrapf(err, "Cannot sync %s", file)
+		}
+	}
+	return strings.Join(parts, remotePathSeparator), nil
+}
+
+// checkRemotePart returns an error if a directory or file name would be split
+// differently when its remote name is read back
+func checkRemotePart(part string) error {
+	if strings.Contains(part, remotePathSeparator) || strings.HasPrefix(part, "_") || strings.HasSuffix(part, "_") {
+		return errors.Errorf("%q contains %q or starts or ends with \"_\", rename it to sync it", part, remotePathSeparator)
+	}
+	return nil
+}
+
+// localFile returns the local path of a remote file of one of the snippet directories
+func localFile(dirs []snippetDir, name string) (string, bool) {
+	parts := strings.Split(name, remotePathSeparator)
+	if len(parts) < 2 || !snippet.IsSnippetFile(name) {
+		return "", false
+	}
+	for _, part := range parts {
+		if part == "" || checkRemotePart(part) != nil {
+			return "", false
+		}
+	}
+	for _, d := range dirs {
+		if d.name == parts[0] {
+			return filepath.Join(append([]string{d.path}, parts[1:]...)...), true
+		}
+	}
+	return "", false
+}
+
+// mainFileName returns the remote name of the main snippet file for the configured backend
+func mainFileName() string {
+	name := config.Conf.Gist.FileName
+	switch config.Conf.General.Backend {
+	case "gitlab":
+		name = config.Conf.GitLab.FileName
+	case "ghe":
+		name = config.Conf.GHEGist.FileName
+	case "s3":
+		name = config.Conf.S3.FileName
+	case "webdav":
+		name = config.Conf.WebDAV.FileName
+	case "gitea":
+		name = giteaFileName(config.Conf.Gitea)
+	case "git":
+		// The main snippet file is stored in the repository under its own name
+		name = filepath.Base(config.Conf.General.SnippetFile)
+	}
+
+	if name == "" {
+		return defaultRemoteFileName
+	}
+	return name
+}
+
+// contentTypes are the media types of snippet files by extension, for backends storing them
+var contentTypes = map[string]string{
+	".toml": "application/toml",
+	".yaml": "application/yaml",
+	".yml":  "application/yaml",
+	".json": "application/json",
+	".md":   "text/markdown",
+}
+
+// contentType returns the media type of a remote snippet file.
+// Files without a known extension are TOML, as the main snippet file.
+func contentType(name string) string {
+	if t, ok := contentTypes[strings.ToLower(filepath.Ext(name))]; ok {
+		return t
+	}
+	return contentTypes[".toml"]
+}
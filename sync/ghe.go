@@ -80,43 +80,38 @@ func (g GHEGistClient) GetSnippet() (*Snippet, error) {
 		return nil, errors.Wrapf(err, "Failed to get gist")
 	}
 
-	content := ""
-	filename := config.Conf.GHEGist.FileName
-	for _, file := range gist.Files {
-		if *file.Filename == filename {
-			content = *file.Content
-		}
-	}
-	if content == "" {
-		return nil, fmt.Errorf("%s is empty", filename)
+	files := map[string]string{}
+	for name, file := range gist.Files {
+		files[string(name)] = file.GetContent()
 	}
 
 	return &Snippet{
-		Content:   content,
+		Files:     files,
 		UpdatedAt: *gist.UpdatedAt,
 	}, nil
 }
 
-// UploadSnippet uploads local snippets to Gist
-func (g GHEGistClient) UploadSnippet(content string) error {
-	gist := &github.Gist{
-		Description: github.String("description"),
-		Public:      github.Bool(config.Conf.GHEGist.Public),
-		Files: map[github.GistFilename]github.GistFile{
-			github.GistFilename(config.Conf.GHEGist.FileName): github.GistFile{
-				Content: github.String(content),
-			},
-		},
-	}
-
+// UploadSnippet uploads local snippet files to Gist
+func (g GHEGistClient) UploadSnippet(files map[string]string) error {
 	if g.ID == "" {
+		gist := &github.Gist{
+			Description: github.String("description"),
+			Public:      github.Bool(config.Conf.GHEGist.Public),
+			Files:       map[github.GistFilename]github.GistFile{},
+		}
+		for name, content := range files {
+			if content != "" {
+				gist.Files[github.GistFilename(name)] = github.GistFile{Content: github.String(content)}
+			}
+		}
+
 		gistID, err := g.createGist(context.Background(), gist)
 		if err != nil {
 			return err
 		}
 		fmt.Printf("Gist ID: %s\n", *gistID)
 	} else {
-		if err := g.updateGist(context.Background(), gist); err != nil {
+		if err := g.updateGist(context.Background(), files); err != nil {
 			return errors.Wrap(err, "Failed to update gist")
 		}
 	}
@@ -136,13 +131,13 @@ func (g GHEGistClient) createGist(ctx context.Context, gist *github.Gist) (gistI
 	return retGist.ID, nil
 }
 
-func (g GHEGistClient) updateGist(ctx context.Context, gist *github.Gist) (err error) {
+func (g GHEGistClient) updateGist(ctx context.Context, files map[string]string) (err error) {
 	s := spinner.New(spinner.CharSets[14], 100*time.Millisecond)
 	s.Start()
 	s.Suffix = " Updating Gist..."
 	defer s.Stop()
 
-	if _, _, err = g.Client.Gists.Edit(ctx, g.ID, gist); err != nil {
+	if err = editGist(ctx, g.Client, g.ID, files); err != nil {
 		return errors.Wrap(err, "Failed to edit gist")
 	}
 	return nil
@@ -68,43 +68,38 @@ func (g GistClient) GetSnippet() (*Snippet, error) {
 		return nil, errors.Wrapf(err, "Failed to get gist")
 	}
 
-	content := ""
-	filename := config.Conf.Gist.FileName
-	for _, file := range gist.Files {
-		if *file.Filename == filename {
-			content = *file.Content
-		}
-	}
-	if content == "" {
-		return nil, fmt.Errorf("%s is empty", filename)
+	files := map[string]string{}
+	for name, file := range gist.Files {
+		files[string(name)] = file.GetContent()
 	}
 
 	return &Snippet{
-		Content:   content,
+		Files:     files,
 		UpdatedAt: *gist.UpdatedAt,
 	}, nil
 }
 
-// UploadSnippet uploads local snippets to Gist
-func (g GistClient) UploadSnippet(content string) error {
-	gist := &github.Gist{
-		Description: github.String("description"),
-		Public:      github.Bool(config.Conf.Gist.Public),
-		Files: map[github.GistFilename]github.GistFile{
-			github.GistFilename(config.Conf.Gist.FileName): github.GistFile{
-				Content: github.String(content),
-			},
-		},
-	}
-
+// UploadSnippet uploads local snippet files to Gist
+func (g GistClient) UploadSnippet(files map[string]string) error {
 	if g.ID == "" {
+		gist := &github.Gist{
+			Description: github.String("description"),
+			Public:      github.Bool(config.Conf.Gist.Public),
+			Files:       map[github.GistFilename]github.GistFile{},
+		}
+		for name, content := range files {
+			if content != "" {
+				gist.Files[github.GistFilename(name)] = github.GistFile{Content: github.String(content)}
+			}
+		}
+
 		gistID, err := g.createGist(context.Background(), gist)
 		if err != nil {
 			return err
 		}
 		fmt.Printf("Gist ID: %s\n", *gistID)
 	} else {
-		if err := g.updateGist(context.Background(), gist); err != nil {
+		if err := g.updateGist(context.Background(), files); err != nil {
 			return errors.Wrap(err, "Failed to update gist")
 		}
 	}
@@ -124,18 +119,44 @@ func (g GistClient) createGist(ctx context.Context, gist *github.Gist) (gistID *
 	return retGist.ID, nil
 }
 
-func (g GistClient) updateGist(ctx context.Context, gist *github.Gist) (err error) {
+func (g GistClient) updateGist(ctx context.Context, files map[string]string) (err error) {
 	s := spinner.New(spinner.CharSets[14], 100*time.Millisecond)
 	s.Start()
 	s.Suffix = " Updating Gist..."
 	defer s.Stop()
 
-	if _, _, err = g.Client.Gists.Edit(ctx, g.ID, gist); err != nil {
+	if err = editGist(ctx, g.Client, g.ID, files); err != nil {
 		return errors.Wrap(err, "Failed to edit gist")
 	}
 	return nil
 }
 
+// editGist updates the given files of a gist, files with an empty content are deleted.
+// Gists.Edit cannot send the null file which deletes a file, so the request is built here.
+func editGist(ctx context.Context, client *github.Client, id string, files map[string]string) error {
+	body := struct {
+		Description string                      `json:"description"`
+		Files       map[string]*github.GistFile `json:"files"`
+	}{
+		Description: "description",
+		Files:       map[string]*github.GistFile{},
+	}
+	for name, content := range files {
+		if content == "" {
+			body.Files[name] = nil
+		} else {
+			body.Files[name] = &github.GistFile{Content: github.String(content)}
+		}
+	}
+
+	req, err := client.NewRequest("PATCH", "gists/"+id, body)
+	if err != nil {
+		return err
+	}
+	_, err = client.Do(ctx, req, nil)
+	return err
+}
+
 func githubClient(accessToken string) *github.Client {
 	ts := oauth2.StaticTokenSource(
 		&oauth2.Token{AccessToken: accessToken},
@@ -0,0 +1,300 @@
+package sync
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/briandowns/spinner"
+	"github.com/knqyf263/pet/config"
+	"github.com/knqyf263/pet/path"
+	"github.com/knqyf263/pet/snippet"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultGitBranch = "main"
+	defaultGitDir    = "git"
+	// gitSnippetDirs is the directory in the repository holding a copy of each snippet directory
+	gitSnippetDirs = "snippetdirs"
+)
+
+// GitClient keeps the snippet files in a git repository. A local clone,
+// updated to the remote branch on each sync, is used to read and push them.
+type GitClient struct {
+	Remote string
+	Branch string
+	Dir    string
+
+	// identity is passed to git when no user is configured, so that pet can still commit
+	identity []string
+}
+
+// NewGitClient returns GitClient
+func NewGitClient() (Client, error) {
+	if config.Conf.Git.Remote == "" {
+		return nil, errors.New(`remote is empty.
+Write the URL of a git repository to remote in [Git] in config file (pet configure).`)
+	}
+
+	client := &GitClient{
+		Remote: config.Conf.Git.Remote,
+		Branch: config.Conf.Git.Branch,
+		Dir:    config.Conf.Git.Dir,
+	}
+	if client.Branch == "" {
+		client.Branch = defaultGitBranch
+	}
+	if client.Dir == "" {
+		dir, err := config.GetDefaultConfigDir()
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to get the default config directory")
+		}
+		client.Dir = filepath.Join(dir, defaultGitDir)
+	}
+
+	dir, err := path.NewAbsolutePath(client.Dir)
+	if err != nil {
+		return nil, err
+	}
+	client.Dir = dir.Get()
+	return client, nil
+}
+
+// GetSnippet updates the clone to the remote branch and returns its snippet files
+func (g *GitClient) GetSnippet() (*Snippet, error) {
+	s := spinner.New(spinner.CharSets[14], 100*time.Millisecond)
+	s.Start()
+	s.Suffix = " Fetching git repository..."
+	defer s.Stop()
+
+	if err := g.open(); err != nil {
+		return nil, errors.Wrap(err, "Failed to open the git repository")
+	}
+
+	snippet := &Snippet{Files: map[string]string{}}
+	remote, err := g.remoteBranchExists()
+	if err != nil || !remote {
+		return snippet, err
+	}
+
+	// The clone only mirrors the remote branch, commits which failed to push are
+	// dropped as the local snippet files still hold their changes
+	if _, err := g.git("fetch", "origin", g.Branch); err != nil {
+		return nil, errors.Wrap(err, "Failed to fetch the remote snippets")
+	}
+	if _, err := g.git("checkout", "--force", "-B", g.Branch, "FETCH_HEAD"); err != nil {
+		return nil, err
+	}
+	if _, err := g.git("clean", "-d", "--force"); err != nil {
+		return nil, err
+	}
+
+	files, err := g.files()
+	if err != nil {
+		return nil, err
+	}
+	for name, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		snippet.Files[name] = string(content)
+	}
+
+	if date, err := g.git("log", "-1", "--format=%cI"); err == nil {
+		snippet.UpdatedAt, _ = time.Parse(time.RFC3339, date)
+	}
+	return snippet, nil
+}
+
+// UploadSnippet writes the snippet files to the clone, commits and pushes them.
+// The push fails rather than overwrite commits pushed since the clone was updated.
+func (g *GitClient) UploadSnippet(files map[string]string) error {
+	s := spinner.New(spinner.CharSets[14], 100*time.Millisecond)
+	s.Start()
+	s.Suffix = " Pushing git repository..."
+	defer s.Stop()
+
+	for name, content := range files {
+		file, err := g.repoFile(name)
+		if err != nil {
+			return err
+		}
+
+		if content == "" {
+			if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+			return err
+		}
+		if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+			return err
+		}
+	}
+
+	if err := g.commit(); err != nil {
+		return err
+	}
+	if _, err := g.git("push", "origin", "HEAD:refs/heads/"+g.Branch); err != nil {
+		if strings.Contains(err.Error(), "[rejected]") {
+			return errRemoteChanged
+		}
+		return errors.Wrap(err, "Failed to push the snippets")
+	}
+	return nil
+}
+
+// mainRepoFile returns the path of the main snippet file in the clone
+func (g *GitClient) mainRepoFile() string {
+	return filepath.Join(g.Dir, mainFileName())
+}
+
+// repoDirs returns the copies of snippet directories in the clone, including those
+// of directories which only other machines have
+func (g *GitClient) repoDirs() ([]snippetDir, error) {
+	entries, err := os.ReadDir(filepath.Join(g.Dir, gitSnippetDirs))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var dirs []snippetDir
+	for _, e := range entries {
+		if e.IsDir() {
+			dirs = append(dirs, snippetDir{path: filepath.Join(g.Dir, gitSnippetDirs, e.Name()), name: e.Name()})
+		}
+	}
+	return dirs, nil
+}
+
+// files returns the snippet files of the clone by their remote name
+func (g *GitClient) files() (map[string]string, error) {
+	files := map[string]string{}
+	if _, err := os.Stat(g.mainRepoFile()); err == nil {
+		files[mainFileName()] = g.mainRepoFile()
+	}
+
+	dirs, err := g.repoDirs()
+	if err != nil {
+		return nil, err
+	}
+	for _, dir := range dirs {
+		for _, file := range snippet.GetFiles(dir.path) {
+			name, err := dir.remoteName(file)
+			if err != nil {
+				return nil, err
+			}
+			files[name] = file
+		}
+	}
+	return files, nil
+}
+
+// repoFile returns the path in the clone of a snippet file by its remote name.
+// The main snippet file is stored at the top of the repository and the files
+// of each snippet directory under snippetdirs/<directory name>.
+func (g *GitClient) repoFile(name string) (string, error) {
+	if name == mainFileName() {
+		return g.mainRepoFile(), nil
+	}
+
+	dirs, err := snippetDirs()
+	if err != nil {
+		return "", err
+	}
+	for i, dir := range dirs {
+		dirs[i].path = filepath.Join(g.Dir, gitSnippetDirs, dir.name)
+	}
+	if file, ok := localFile(dirs, name); ok {
+		return file, nil
+	}
+	return "", errors.Errorf("%s belongs to no snippet directory", name)
+}
+
+// open prepares the local clone of the remote repository
+func (g *GitClient) open() error {
+	if _, err := os.Stat(filepath.Join(g.Dir, ".git")); err == nil {
+		g.setIdentity()
+		_, err := g.git("remote", "set-url", "origin", g.Remote)
+		return err
+	}
+
+	if err := os.MkdirAll(g.Dir, 0700); err != nil {
+		return err
+	}
+	if _, err := g.git("init"); err != nil {
+		return err
+	}
+	g.setIdentity()
+	if _, err := g.git("remote", "add", "origin", g.Remote); err != nil {
+		return err
+	}
+	_, err := g.git("symbolic-ref", "HEAD", "refs/heads/"+g.Branch)
+	return err
+}
+
+// setIdentity falls back to a pet identity when git has no user configured
+func (g *GitClient) setIdentity() {
+	if _, err := g.git("config", "user.email"); err != nil {
+		g.identity = []string{"-c", "user.name=pet", "-c", "user.email=pet@localhost"}
+	}
+}
+
+func (g *GitClient) remoteBranchExists() (bool, error) {
+	_, err := g.git("ls-remote", "--exit-code", "--heads", "origin", g.Branch)
+	if exitErr, ok := errors.Cause(err).(*exec.ExitError); ok && exitErr.ExitCode() == 2 {
+		return false, nil
+	} else if err != nil {
+		return false, errors.Wrap(err, "Failed to access the remote repository")
+	}
+	return true, nil
+}
+
+// commit records all changes in the clone, if there are any
+func (g *GitClient) commit() error {
+	if _, err := g.git("add", "--all"); err != nil {
+		return err
+	}
+
+	status, err := g.git("status", "--porcelain")
+	if err != nil {
+		return err
+	}
+	if status == "" {
+		return nil
+	}
+
+	message := "Update snippets"
+	if host, err := os.Hostname(); err == nil {
+		message = fmt.Sprintf("Update snippets from %s", host)
+	}
+	_, err = g.git("commit", "--message", message)
+	return err
+}
+
+// git runs a git command in the clone and returns its trimmed output
+func (g *GitClient) git(args ...string) (string, error) {
+	args = append(append([]string{"-C", g.Dir}, g.identity...), args...)
+	cmd := exec.Command("git", args...)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			return "", err
+		}
+		return "", errors.Wrap(err, msg)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
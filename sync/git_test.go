@@ -0,0 +1,205 @@
+package sync
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/knqyf263/pet/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// gitMachine is the local setup of one machine sharing a remote repository
+type gitMachine struct {
+	configDir   string
+	snippetFile string
+	snippetDir  string
+	conf        config.Config
+}
+
+func newGitRemote(t *testing.T) string {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	remote := filepath.Join(t.TempDir(), "remote.git")
+	out, err := exec.Command("git", "init", "--bare", remote).CombinedOutput()
+	require.NoError(t, err, string(out))
+	return remote
+}
+
+func newGitMachine(t *testing.T, remote string) *gitMachine {
+	dir := t.TempDir()
+	m := &gitMachine{
+		configDir:   filepath.Join(dir, "config"),
+		snippetFile: filepath.Join(dir, "snippet.toml"),
+		snippetDir:  filepath.Join(dir, "snippets"),
+	}
+	require.NoError(t, os.WriteFile(m.snippetFile, nil, 0644))
+	require.NoError(t, os.MkdirAll(m.snippetDir, 0755))
+
+	m.conf.General.Backend = "git"
+	m.conf.General.SnippetFile = m.snippetFile
+	m.conf.General.SnippetDirs = []string{m.snippetDir}
+	m.conf.Git = config.GitConfig{
+		Remote: remote,
+		Branch: "main",
+		Dir:    filepath.Join(dir, "clone"),
+	}
+	return m
+}
+
+// use makes the machine the one pet runs on
+func (m *gitMachine) use(t *testing.T) {
+	orig := config.Conf
+	t.Cleanup(func() { config.Conf = orig })
+	config.Conf = m.conf
+	t.Setenv("PET_CONFIG_DIR", m.configDir)
+}
+
+// sync syncs the machine, answering conflicts with answers
+func (m *gitMachine) sync(t *testing.T, answers string) error {
+	m.use(t)
+	client, err := NewGitClient()
+	require.NoError(t, err)
+	return merge(client, Options{}, strings.NewReader(answers), &bytes.Buffer{})
+}
+
+func writeFile(t *testing.T, file, content string) {
+	require.NoError(t, os.MkdirAll(filepath.Dir(file), 0755))
+	require.NoError(t, os.WriteFile(file, []byte(content), 0644))
+}
+
+func readFile(t *testing.T, file string) string {
+	content, err := os.ReadFile(file)
+	require.NoError(t, err)
+	return string(content)
+}
+
+func TestGitSync(t *testing.T) {
+	remote := newGitRemote(t)
+	a := newGitMachine(t, remote)
+	b := newGitMachine(t, remote)
+
+	writeFile(t, a.snippetFile, "[[Snippets]]\n  command = \"ls\"\n")
+	writeFile(t, filepath.Join(a.snippetDir, "docker.toml"), "[[Snippets]]\n  command = \"docker ps\"\n")
+	writeFile(t, filepath.Join(a.snippetDir, "k8s", "pods.toml"), "[[Snippets]]\n  command = \"kubectl get pods\"\n")
+	require.NoError(t, a.sync(t, ""))
+
+	// The repository keeps the main file at the top and the snippet directories below snippetdirs
+	assert.FileExists(t, filepath.Join(a.conf.Git.Dir, "snippet.toml"))
+	assert.FileExists(t, filepath.Join(a.conf.Git.Dir, gitSnippetDirs, "snippets", "k8s", "pods.toml"))
+
+	// A new machine takes the snippets of the remote
+	require.NoError(t, b.sync(t, ""))
+	assert.Equal(t, readFile(t, a.snippetFile), readFile(t, b.snippetFile))
+	assert.Equal(t, []string{"docker ps"}, snippetsOf(t, readFile(t, filepath.Join(b.snippetDir, "docker.toml"))))
+	assert.Equal(t, []string{"kubectl get pods"}, snippetsOf(t, readFile(t, filepath.Join(b.snippetDir, "k8s", "pods.toml"))))
+
+	// Changes and deletions made on one machine reach the other
+	docker := filepath.Join(b.snippetDir, "docker.toml")
+	writeFile(t, docker, strings.Replace(readFile(t, docker), `"docker ps"`, `"docker ps -a"`, 1))
+	require.NoError(t, os.Remove(filepath.Join(b.snippetDir, "k8s", "pods.toml")))
+	require.NoError(t, b.sync(t, ""))
+
+	require.NoError(t, a.sync(t, ""))
+	assert.Equal(t, []string{"docker ps -a"}, snippetsOf(t, readFile(t, filepath.Join(a.snippetDir, "docker.toml"))))
+	assert.NoFileExists(t, filepath.Join(a.snippetDir, "k8s", "pods.toml"))
+	// The deleted file is kept as its backup
+	assert.FileExists(t, filepath.Join(a.snippetDir, "k8s", "pods.toml.bak"))
+}
+
+func TestGitSyncMergesChangesOfBothMachines(t *testing.T) {
+	remote := newGitRemote(t)
+	a := newGitMachine(t, remote)
+	b := newGitMachine(t, remote)
+
+	writeFile(t, a.snippetFile, "[[Snippets]]\n  command = \"ls\"\n")
+	require.NoError(t, a.sync(t, ""))
+	require.NoError(t, b.sync(t, ""))
+
+	writeFile(t, filepath.Join(a.snippetDir, "a.toml"), "[[Snippets]]\n  command = \"echo a\"\n")
+	require.NoError(t, a.sync(t, ""))
+	writeFile(t, filepath.Join(b.snippetDir, "b.toml"), "[[Snippets]]\n  command = \"echo b\"\n")
+	require.NoError(t, b.sync(t, ""))
+	require.NoError(t, a.sync(t, ""))
+
+	for _, m := range []*gitMachine{a, b} {
+		assert.FileExists(t, filepath.Join(m.snippetDir, "a.toml"))
+		assert.FileExists(t, filepath.Join(m.snippetDir, "b.toml"))
+	}
+}
+
+func TestGitSyncNewMachineKeepsLocalSnippets(t *testing.T) {
+	remote := newGitRemote(t)
+	a := newGitMachine(t, remote)
+	b := newGitMachine(t, remote)
+
+	writeFile(t, a.snippetFile, "[[Snippets]]\n  command = \"ls\"\n")
+	require.NoError(t, a.sync(t, ""))
+
+	// The first sync of a machine with snippets of its own merges both
+	writeFile(t, b.snippetFile, "[[Snippets]]\n  command = \"df\"\n")
+	require.NoError(t, b.sync(t, ""))
+	assert.ElementsMatch(t, []string{"ls", "df"}, snippetsOf(t, readFile(t, b.snippetFile)))
+
+	require.NoError(t, a.sync(t, ""))
+	assert.ElementsMatch(t, []string{"ls", "df"}, snippetsOf(t, readFile(t, a.snippetFile)))
+}
+
+func TestGitSyncConflict(t *testing.T) {
+	remote := newGitRemote(t)
+	a := newGitMachine(t, remote)
+	b := newGitMachine(t, remote)
+
+	writeFile(t, a.snippetFile, "[[Snippets]]\n  id = \"1\"\n  command = \"ls\"\n")
+	require.NoError(t, a.sync(t, ""))
+	require.NoError(t, b.sync(t, ""))
+
+	writeFile(t, a.snippetFile, "[[Snippets]]\n  id = \"1\"\n  command = \"ls -l\"\n")
+	require.NoError(t, a.sync(t, ""))
+	writeFile(t, b.snippetFile, "[[Snippets]]\n  id = \"1\"\n  command = \"ls -a\"\n")
+
+	// The conflicting snippet is resolved like with any other backend
+	require.NoError(t, b.sync(t, "l\n"))
+	assert.Equal(t, []string{"ls -a"}, snippetsOf(t, readFile(t, b.snippetFile)))
+
+	require.NoError(t, a.sync(t, ""))
+	assert.Equal(t, []string{"ls -a"}, snippetsOf(t, readFile(t, a.snippetFile)))
+}
+
+func TestGitUploadRefusesRemoteChanges(t *testing.T) {
+	remote := newGitRemote(t)
+	a := newGitMachine(t, remote)
+	b := newGitMachine(t, remote)
+
+	writeFile(t, a.snippetFile, "[[Snippets]]\n  command = \"ls\"\n")
+	require.NoError(t, a.sync(t, ""))
+
+	b.use(t)
+	client, err := NewGitClient()
+	require.NoError(t, err)
+	_, err = client.GetSnippet()
+	require.NoError(t, err)
+
+	// Another machine pushes in the meantime
+	writeFile(t, a.snippetFile, "[[Snippets]]\n  command = \"ls -l\"\n")
+	require.NoError(t, a.sync(t, ""))
+
+	b.use(t)
+	err = client.UploadSnippet(map[string]string{"snippet.toml": "[[Snippets]]\n  command = \"df\"\n"})
+	assert.Equal(t, errRemoteChanged, err)
+}
+
+func TestNewGitClientWithoutRemote(t *testing.T) {
+	orig := config.Conf
+	defer func() { config.Conf = orig }()
+	config.Conf = config.Config{}
+
+	_, err := NewGitClient()
+	assert.Error(t, err)
+}
@@ -0,0 +1,379 @@
+package sync
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/briandowns/spinner"
+	"github.com/knqyf263/pet/config"
+	"github.com/knqyf263/pet/snippet"
+	"github.com/pkg/errors"
+)
+
+const (
+	giteaTokenEnvVariable = "PET_GITEA_ACCESS_TOKEN"
+	giteaCommitMessage    = "Update snippets by pet"
+)
+
+// GiteaClient manages communication with a repository on Gitea or Forgejo.
+// Each snippet file is a file in the directory Path of the repository,
+// read and written with the contents API.
+type GiteaClient struct {
+	Client      *http.Client
+	BaseUrl     string
+	AccessToken string
+	Repo        string
+	Branch      string
+	Path        string
+
+	// shas are the blob SHAs of the files as they were read, so that
+	// uploads fail rather than overwrite files changed in the meantime
+	shas map[string]string
+}
+
+// NewGiteaClient returns GiteaClient
+func NewGiteaClient() (Client, error) {
+	conf := config.Conf.Gitea
+
+	accessToken := conf.AccessToken
+	if accessToken == "" {
+		accessToken = os.Getenv(giteaTokenEnvVariable)
+	}
+	if accessToken == "" {
+		return nil, fmt.Errorf(`access_token is empty.
+Create an access token with repository read and write scopes in the settings of your Gitea or Forgejo account.
+Write access_token in config file (pet configure) or export $%v.
+		`, giteaTokenEnvVariable)
+	}
+
+	if conf.Url == "" {
+		return nil, errors.New(`url is empty.
+Write the URL of your Gitea or Forgejo server to url in [Gitea] in config file (pet configure).`)
+	}
+	if strings.Count(conf.Repo, "/") != 1 {
+		return nil, errors.Errorf(`Invalid Gitea repo: %q.
+Write the repository as "owner/name" to repo in [Gitea] in config file (pet configure).`, conf.Repo)
+	}
+
+	h := &http.Client{Timeout: 30 * time.Second}
+	if conf.SkipSsl {
+		h.Transport = &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		}
+	}
+
+	baseUrl := strings.TrimSuffix(conf.Url, "/")
+	if !strings.HasSuffix(baseUrl, "/api/v1") {
+		baseUrl += "/api/v1"
+	}
+
+	return &GiteaClient{
+		Client:      h,
+		BaseUrl:     baseUrl,
+		AccessToken: accessToken,
+		Repo:        conf.Repo,
+		Branch:      conf.Branch,
+		Path:        giteaDir(conf),
+		shas:        map[string]string{},
+	}, nil
+}
+
+// giteaDir returns the directory of the snippet files in the repository. path in [Gitea]
+// is either that directory or the main snippet file, the other files are stored next to it.
+func giteaDir(conf config.GiteaConfig) string {
+	p := strings.Trim(conf.Path, "/")
+	if !isGiteaFilePath(p) {
+		return p
+	}
+	if dir := path.Dir(p); dir != "." {
+		return dir
+	}
+	return ""
+}
+
+// giteaFileName returns the remote name of the main snippet file,
+// the name of path in [Gitea] if it names a file and file_name otherwise
+func giteaFileName(conf config.GiteaConfig) string {
+	if p := strings.Trim(conf.Path, "/"); isGiteaFilePath(p) {
+		return path.Base(p)
+	}
+	return conf.FileName
+}
+
+// isGiteaFilePath reports whether path in [Gitea] names a snippet file rather than a directory
+func isGiteaFilePath(p string) bool {
+	base := path.Base(p)
+	return p != "" && snippet.IsSnippetFile(base) && !snippet.IsReadOnly(base)
+}
+
+// giteaContent is a file or directory returned by the contents API
+type giteaContent struct {
+	Name     string `json:"name"`
+	Path     string `json:"path"`
+	Sha      string `json:"sha"`
+	Type     string `json:"type"`
+	Encoding string `json:"encoding"`
+	Content  string `json:"content"`
+}
+
+// giteaFileOptions is the body of requests which change a file
+type giteaFileOptions struct {
+	Content string `json:"content,omitempty"`
+	Sha     string `json:"sha,omitempty"`
+	Branch  string `json:"branch,omitempty"`
+	Message string `json:"message"`
+}
+
+// giteaFileResponse is the response of requests which change a file
+type giteaFileResponse struct {
+	Content *giteaContent `json:"content"`
+}
+
+type giteaCommit struct {
+	Commit struct {
+		Committer struct {
+			Date time.Time `json:"date"`
+		} `json:"committer"`
+	} `json:"commit"`
+}
+
+type giteaError struct {
+	Message string `json:"message"`
+}
+
+// GetSnippet returns the snippet files in the directory of the repository
+func (g *GiteaClient) GetSnippet() (*Snippet, error) {
+	s := spinner.New(spinner.CharSets[14], 100*time.Millisecond)
+	s.Start()
+	s.Suffix = " Getting Gitea files..."
+	defer s.Stop()
+
+	snippet := &Snippet{Files: map[string]string{}}
+
+	var listing json.RawMessage
+	err := g.do(http.MethodGet, g.contentsPath(""), g.refQuery(), nil, &listing)
+	if err == errGiteaNotFound {
+		// The directory is created by the first upload
+		return snippet, nil
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "Failed to list Gitea files in %s", g.describe())
+	}
+	// The contents of a file are an object, those of a directory a list
+	var entries []giteaContent
+	if bytes.HasPrefix(bytes.TrimSpace(listing), []byte("{")) {
+		return nil, errors.Errorf("%s is a file, path in [Gitea] must be the directory of the snippet files or name a .toml, .yaml or .json file", g.describe())
+	}
+	if err := json.Unmarshal(listing, &entries); err != nil {
+		return nil, errors.Wrapf(err, "Failed to list Gitea files in %s", g.describe())
+	}
+
+	for _, entry := range entries {
+		if entry.Type != "file" {
+			continue
+		}
+
+		var file giteaContent
+		if err := g.do(http.MethodGet, g.contentsPath(entry.Name), g.refQuery(), nil, &file); err != nil {
+			return nil, errors.Wrapf(err, "Failed to get Gitea file %s", entry.Path)
+		}
+		content, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(file.Content, "\n", ""))
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to decode Gitea file %s", entry.Path)
+		}
+
+		snippet.Files[entry.Name] = string(content)
+		g.shas[entry.Name] = file.Sha
+	}
+
+	if len(snippet.Files) > 0 {
+		updatedAt, err := g.lastCommitTime()
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to get the last commit of %s", g.describe())
+		}
+		snippet.UpdatedAt = updatedAt
+	}
+	return snippet, nil
+}
+
+// UploadSnippet commits the snippet files, only if nobody else changed them since they were read
+func (g *GiteaClient) UploadSnippet(files map[string]string) error {
+	s := spinner.New(spinner.CharSets[14], 100*time.Millisecond)
+	s.Start()
+	s.Suffix = " Uploading Gitea files..."
+	defer s.Stop()
+
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		sha, exists := g.shas[name]
+		opts := giteaFileOptions{
+			Sha:     sha,
+			Branch:  g.Branch,
+			Message: giteaCommitMessage,
+		}
+
+		if files[name] == "" {
+			if !exists {
+				continue
+			}
+			if err := g.do(http.MethodDelete, g.contentsPath(name), nil, opts, nil); err != nil {
+				return errors.Wrapf(err, "Failed to delete Gitea file %s", name)
+			}
+			delete(g.shas, name)
+			continue
+		}
+
+		// Gitea creates files with POST and updates them with PUT
+		method := http.MethodPost
+		if exists {
+			method = http.MethodPut
+		}
+		opts.Content = base64.StdEncoding.EncodeToString([]byte(files[name]))
+
+		var res giteaFileResponse
+		if err := g.do(method, g.contentsPath(name), nil, opts, &res); err != nil {
+			return errors.Wrapf(err, "Failed to write Gitea file %s", name)
+		}
+		if res.Content != nil {
+			g.shas[name] = res.Content.Sha
+		}
+	}
+	return nil
+}
+
+// lastCommitTime returns when the snippet directory was last changed on the branch
+func (g *GiteaClient) lastCommitTime() (time.Time, error) {
+	query := url.Values{}
+	if g.Branch != "" {
+		query.Set("sha", g.Branch)
+	}
+	if g.Path != "" {
+		query.Set("path", g.Path)
+	}
+	query.Set("limit", "1")
+	query.Set("stat", "false")
+
+	var commits []giteaCommit
+	if err := g.do(http.MethodGet, g.repoPath("commits"), query, nil, &commits); err != nil {
+		return time.Time{}, err
+	}
+	if len(commits) == 0 {
+		return time.Time{}, nil
+	}
+	return commits[0].Commit.Committer.Date, nil
+}
+
+// describe names the directory of the snippet files in messages
+func (g *GiteaClient) describe() string {
+	s := g.Repo + "/" + g.Path
+	if g.Branch != "" {
+		s += "@" + g.Branch
+	}
+	return s
+}
+
+func (g *GiteaClient) refQuery() url.Values {
+	if g.Branch == "" {
+		return nil
+	}
+	return url.Values{"ref": {g.Branch}}
+}
+
+// repoPath returns the escaped API path of an endpoint of the repository
+func (g *GiteaClient) repoPath(endpoint string) string {
+	owner, name, _ := strings.Cut(g.Repo, "/")
+	return "/repos/" + url.PathEscape(owner) + "/" + url.PathEscape(name) + "/" + endpoint
+}
+
+// contentsPath returns the escaped API path of a file in the snippet directory, or of the directory if name is empty
+func (g *GiteaClient) contentsPath(name string) string {
+	var segments []string
+	for _, s := range strings.Split(g.Path, "/") {
+		if s != "" {
+			segments = append(segments, url.PathEscape(s))
+		}
+	}
+	if name != "" {
+		segments = append(segments, url.PathEscape(name))
+	}
+	if len(segments) == 0 {
+		return g.repoPath("contents")
+	}
+	return g.repoPath("contents/" + strings.Join(segments, "/"))
+}
+
+// errGiteaNotFound is returned when the repository, branch or file does not exist
+var errGiteaNotFound = errors.New("repository, branch or file not found")
+
+// do sends an authenticated request to the API and decodes the JSON response into out
+func (g *GiteaClient) do(method, apiPath string, query url.Values, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+
+	u := g.BaseUrl + apiPath
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "token "+g.AccessToken)
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := g.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	resBody, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	if res.StatusCode == http.StatusNotFound {
+		return errGiteaNotFound
+	}
+	if res.StatusCode >= 300 {
+		var giteaErr giteaError
+		if json.Unmarshal(resBody, &giteaErr) != nil || giteaErr.Message == "" {
+			return errors.New(res.Status)
+		}
+		// Gitea rejects a stale sha and the creation of an existing file as unprocessable
+		if res.StatusCode == http.StatusUnprocessableEntity &&
+			(strings.Contains(giteaErr.Message, "sha does not match") || strings.Contains(giteaErr.Message, "already exists")) {
+			return errRemoteChanged
+		}
+		return errors.Errorf("%s: %s", res.Status, giteaErr.Message)
+	}
+
+	if out == nil || len(resBody) == 0 {
+		return nil
+	}
+	return json.Unmarshal(resBody, out)
+}
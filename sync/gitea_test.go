@@ -0,0 +1,275 @@
+package sync
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/knqyf263/pet/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var giteaCommitted = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// fakeGitea serves the contents API of a single repository kept in memory
+type fakeGitea struct {
+	t       *testing.T
+	mu      sync.Mutex
+	files   map[string]string // by path in the repository
+	commits int
+}
+
+func (f *fakeGitea) sha(content string) string {
+	sum := sha1.Sum([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func (f *fakeGitea) fail(w http.ResponseWriter, status int, message string) {
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(giteaError{Message: message})
+}
+
+func (f *fakeGitea) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.Header.Get("Authorization") != "token secret" {
+		f.fail(w, http.StatusUnauthorized, "token is required")
+		return
+	}
+
+	if r.URL.Path == "/api/v1/repos/me/dotfiles/commits" {
+		assert.Equal(f.t, "main", r.URL.Query().Get("sha"))
+		assert.Equal(f.t, "pet/snippets", r.URL.Query().Get("path"))
+		var commit giteaCommit
+		commit.Commit.Committer.Date = giteaCommitted
+		json.NewEncoder(w).Encode([]giteaCommit{commit})
+		return
+	}
+
+	p, ok := strings.CutPrefix(r.URL.Path, "/api/v1/repos/me/dotfiles/contents/")
+	if !ok {
+		f.fail(w, http.StatusNotFound, "repository does not exist")
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		assert.Equal(f.t, "main", r.URL.Query().Get("ref"))
+		if content, ok := f.files[p]; ok {
+			json.NewEncoder(w).Encode(giteaContent{
+				Name:     p[strings.LastIndex(p, "/")+1:],
+				Path:     p,
+				Sha:      f.sha(content),
+				Type:     "file",
+				Encoding: "base64",
+				Content:  base64.StdEncoding.EncodeToString([]byte(content)),
+			})
+			return
+		}
+
+		var entries []giteaContent
+		for path := range f.files {
+			if name, ok := strings.CutPrefix(path, p+"/"); ok {
+				entry := giteaContent{Name: name, Path: path, Type: "file"}
+				if i := strings.Index(name, "/"); i >= 0 {
+					entry = giteaContent{Name: name[:i], Path: p + "/" + name[:i], Type: "dir"}
+				}
+				entries = append(entries, entry)
+			}
+		}
+		if entries == nil {
+			f.fail(w, http.StatusNotFound, "object does not exist")
+			return
+		}
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+		json.NewEncoder(w).Encode(entries)
+		return
+	}
+
+	var opts giteaFileOptions
+	require.NoError(f.t, json.NewDecoder(r.Body).Decode(&opts))
+	assert.Equal(f.t, "main", opts.Branch)
+	assert.NotEmpty(f.t, opts.Message)
+
+	content, exists := f.files[p]
+	switch r.Method {
+	case http.MethodPost:
+		if exists {
+			f.fail(w, http.StatusUnprocessableEntity, "repository file already exists [path: "+p+"]")
+			return
+		}
+	case http.MethodPut, http.MethodDelete:
+		if !exists {
+			f.fail(w, http.StatusNotFound, "file does not exist")
+			return
+		}
+		if opts.Sha != f.sha(content) {
+			f.fail(w, http.StatusUnprocessableEntity, "sha does not match [given: "+opts.Sha+", expected: "+f.sha(content)+"]")
+			return
+		}
+	}
+
+	f.commits++
+	if r.Method == http.MethodDelete {
+		delete(f.files, p)
+		json.NewEncoder(w).Encode(giteaFileResponse{})
+		return
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(opts.Content)
+	require.NoError(f.t, err)
+	f.files[p] = string(decoded)
+	if r.Method == http.MethodPost {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(giteaFileResponse{Content: &giteaContent{Path: p, Sha: f.sha(string(decoded))}})
+}
+
+func newFakeGiteaClient(t *testing.T) (*GiteaClient, *fakeGitea) {
+	fake := &fakeGitea{t: t, files: map[string]string{}}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	orig := config.Conf
+	t.Cleanup(func() { config.Conf = orig })
+	config.Conf.Gitea = config.GiteaConfig{
+		Url:         server.URL + "/",
+		AccessToken: "secret",
+		Repo:        "me/dotfiles",
+		Branch:      "main",
+		Path:        "/pet/snippets/",
+	}
+
+	client, err := NewGiteaClient()
+	require.NoError(t, err)
+	return client.(*GiteaClient), fake
+}
+
+func TestGiteaClient(t *testing.T) {
+	client, fake := newFakeGiteaClient(t)
+	fake.files["pet/snippets/team__docker.toml"] = "docker"
+	fake.files["pet/snippets/nested/other.toml"] = "not ours"
+	fake.files["README.md"] = "not ours"
+
+	snippet, err := client.GetSnippet()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"team__docker.toml": "docker"}, snippet.Files)
+	assert.True(t, giteaCommitted.Equal(snippet.UpdatedAt))
+
+	require.NoError(t, client.UploadSnippet(map[string]string{
+		"pet-snippet.toml":  "main",
+		"team__docker.toml": "",
+	}))
+	assert.Equal(t, map[string]string{
+		"pet/snippets/pet-snippet.toml":  "main",
+		"pet/snippets/nested/other.toml": "not ours",
+		"README.md":                      "not ours",
+	}, fake.files)
+	assert.Equal(t, 2, fake.commits)
+
+	// Uploads after an upload use the new SHAs
+	require.NoError(t, client.UploadSnippet(map[string]string{"pet-snippet.toml": "main 2"}))
+	assert.Equal(t, "main 2", fake.files["pet/snippets/pet-snippet.toml"])
+}
+
+func TestGiteaClientWithoutDirectory(t *testing.T) {
+	client, fake := newFakeGiteaClient(t)
+
+	snippet, err := client.GetSnippet()
+	require.NoError(t, err)
+	assert.Empty(t, snippet.Files)
+	assert.True(t, snippet.UpdatedAt.IsZero())
+
+	require.NoError(t, client.UploadSnippet(map[string]string{"pet-snippet.toml": "main"}))
+	assert.Equal(t, map[string]string{"pet/snippets/pet-snippet.toml": "main"}, fake.files)
+}
+
+func TestGiteaClientPathOfFile(t *testing.T) {
+	client, fake := newFakeGiteaClient(t)
+	fake.files["pet/snippets"] = "snippets in a file"
+
+	_, err := client.GetSnippet()
+	assert.ErrorContains(t, err, "me/dotfiles/pet/snippets@main is a file, path in [Gitea] must be the directory of the snippet files")
+}
+
+func TestGiteaClientDoesNotOverwriteConcurrentChanges(t *testing.T) {
+	client, fake := newFakeGiteaClient(t)
+	fake.files["pet/snippets/pet-snippet.toml"] = "main"
+
+	_, err := client.GetSnippet()
+	require.NoError(t, err)
+
+	// Another machine uploads in the meantime
+	fake.files["pet/snippets/pet-snippet.toml"] = "changed elsewhere"
+	fake.files["pet/snippets/team__new.toml"] = "created elsewhere"
+
+	err = client.UploadSnippet(map[string]string{"pet-snippet.toml": "main 2"})
+	assert.ErrorContains(t, err, "changed since they were read")
+	err = client.UploadSnippet(map[string]string{"team__new.toml": "new"})
+	assert.ErrorContains(t, err, "changed since they were read")
+
+	assert.Equal(t, "changed elsewhere", fake.files["pet/snippets/pet-snippet.toml"])
+	assert.Equal(t, "created elsewhere", fake.files["pet/snippets/team__new.toml"])
+}
+
+func TestGiteaClientErrors(t *testing.T) {
+	client, _ := newFakeGiteaClient(t)
+
+	client.AccessToken = "wrong"
+	_, err := client.GetSnippet()
+	assert.ErrorContains(t, err, "401 Unauthorized: token is required")
+}
+
+func TestNewGiteaClient(t *testing.T) {
+	orig := config.Conf
+	defer func() { config.Conf = orig }()
+	t.Setenv(giteaTokenEnvVariable, "")
+
+	config.Conf.Gitea = config.GiteaConfig{Url: "https://codeberg.org", Repo: "me/dotfiles"}
+	_, err := NewGiteaClient()
+	assert.ErrorContains(t, err, "access_token is empty")
+
+	t.Setenv(giteaTokenEnvVariable, "secret")
+	config.Conf.Gitea = config.GiteaConfig{Url: "https://codeberg.org", Repo: "dotfiles"}
+	_, err = NewGiteaClient()
+	assert.ErrorContains(t, err, "Invalid Gitea repo")
+
+	config.Conf.Gitea = config.GiteaConfig{Url: "https://codeberg.org/api/v1/", Repo: "me/dotfiles"}
+	client, err := NewGiteaClient()
+	require.NoError(t, err)
+	assert.Equal(t, "https://codeberg.org/api/v1", client.(*GiteaClient).BaseUrl)
+	assert.Equal(t, "/repos/me/dotfiles/contents", client.(*GiteaClient).contentsPath(""))
+	assert.Equal(t, "/repos/me/dotfiles/contents/my%20notes.toml", client.(*GiteaClient).contentsPath("my notes.toml"))
+}
+
+func TestGiteaPathOfMainFile(t *testing.T) {
+	orig := config.Conf
+	defer func() { config.Conf = orig }()
+	config.Conf.General.Backend = "gitea"
+
+	tests := []struct {
+		path     string
+		dir      string
+		fileName string
+	}{
+		{path: "", dir: "", fileName: "pet-snippet.toml"},
+		{path: "/pet/snippets/", dir: "pet/snippets", fileName: "pet-snippet.toml"},
+		{path: "pet/snippets/main.toml", dir: "pet/snippets", fileName: "main.toml"},
+		{path: "snippets.yaml", dir: "", fileName: "snippets.yaml"},
+		{path: "docs/runbook.md", dir: "docs/runbook.md", fileName: "pet-snippet.toml"},
+	}
+	for _, tt := range tests {
+		config.Conf.Gitea = config.GiteaConfig{Path: tt.path, FileName: "pet-snippet.toml"}
+		assert.Equal(t, tt.dir, giteaDir(config.Conf.Gitea), tt.path)
+		assert.Equal(t, tt.fileName, mainFileName(), tt.path)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/briandowns/spinner"
@@ -107,55 +108,67 @@ func (g GitLabClient) GetSnippet() (*Snippet, error) {
 		return nil, errors.Wrapf(err, "Failed to get GitLab Snippet (ID: %d)", g.ID)
 	}
 
-	filename := config.Conf.GitLab.FileName
-	if snippet.FileName != filename {
-		return nil, fmt.Errorf("No snippet file in GitLab Snippet (ID: %d)", g.ID)
-	}
-
-	contentByte, _, err := g.Client.Snippets.SnippetContent(g.ID)
-	if err != nil {
-		return nil, errors.Wrapf(err, "Failed to get GitLab Snippet content (ID: %d)", g.ID)
+	files := map[string]string{}
+	for _, file := range snippet.Files {
+		content, _, err := g.Client.Snippets.SnippetFileContent(g.ID, snippetFileRef(file.RawURL, file.Path), file.Path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to get GitLab Snippet content of %s (ID: %d)", file.Path, g.ID)
+		}
+		files[file.Path] = string(content)
 	}
 
-	content := string(contentByte)
-	if content == "" {
-		return nil, fmt.Errorf("%s is empty", filename)
+	// Snippets of older GitLab versions hold a single file
+	if len(snippet.Files) == 0 && snippet.FileName != "" {
+		content, _, err := g.Client.Snippets.SnippetContent(g.ID)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to get GitLab Snippet content (ID: %d)", g.ID)
+		}
+		files[snippet.FileName] = string(content)
 	}
 
 	return &Snippet{
-		Content:   content,
+		Files:     files,
 		UpdatedAt: *snippet.UpdatedAt,
 	}, nil
 }
 
-// UploadSnippet uploads local snippets to GitLab Snippet
-func (g GitLabClient) UploadSnippet(content string) error {
+// UploadSnippet uploads local snippet files to GitLab Snippet
+func (g GitLabClient) UploadSnippet(files map[string]string) error {
 	if g.ID == 0 {
-		id, err := g.createSnippet(context.Background(), content)
+		id, err := g.createSnippet(context.Background(), files)
 		if err != nil {
 			return errors.Wrap(err, "Failed to create GitLab Snippet")
 		}
 		fmt.Printf("GitLab Snippet ID: %d\n", id)
 	} else {
-		if err := g.updateSnippet(context.Background(), content); err != nil {
+		if err := g.updateSnippet(context.Background(), files); err != nil {
 			return errors.Wrap(err, "Failed to update GitLab Snippet")
 		}
 	}
 	return nil
 }
 
-func (g GitLabClient) createSnippet(ctx context.Context, content string) (id int, err error) {
+func (g GitLabClient) createSnippet(ctx context.Context, files map[string]string) (id int, err error) {
 	s := spinner.New(spinner.CharSets[14], 100*time.Millisecond)
 	s.Start()
 	s.Suffix = " Creating GitLab Snippet..."
 	defer s.Stop()
 
+	var snippetFiles []*gitlab.CreateSnippetFileOptions
+	for name, content := range files {
+		if content != "" {
+			snippetFiles = append(snippetFiles, &gitlab.CreateSnippetFileOptions{
+				FilePath: gitlab.String(name),
+				Content:  gitlab.String(content),
+			})
+		}
+	}
+
 	opt := &gitlab.CreateSnippetOptions{
 		Title:       gitlab.String("pet-snippet"),
-		FileName:    gitlab.String(config.Conf.GitLab.FileName),
 		Description: gitlab.String("Snippet file generated by pet"),
-		Content:     gitlab.String(content),
 		Visibility:  gitlab.Visibility(gitlab.VisibilityValue(config.Conf.GitLab.Visibility)),
+		Files:       &snippetFiles,
 	}
 
 	ret, _, err := g.Client.Snippets.CreateSnippet(opt)
@@ -165,18 +178,54 @@ func (g GitLabClient) createSnippet(ctx context.Context, content string) (id int
 	return ret.ID, nil
 }
 
-func (g GitLabClient) updateSnippet(ctx context.Context, content string) (err error) {
+func (g GitLabClient) updateSnippet(ctx context.Context, files map[string]string) (err error) {
 	s := spinner.New(spinner.CharSets[14], 100*time.Millisecond)
 	s.Start()
 	s.Suffix = " Updating GitLab Snippet..."
 	defer s.Stop()
 
+	snippet, _, err := g.Client.Snippets.GetSnippet(g.ID)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to get GitLab Snippet (ID: %d)", g.ID)
+	}
+	existing := map[string]bool{}
+	if snippet.FileName != "" {
+		existing[snippet.FileName] = true
+	}
+	for _, file := range snippet.Files {
+		existing[file.Path] = true
+	}
+
+	var snippetFiles []*gitlab.UpdateSnippetFileOptions
+	for name, content := range files {
+		action := "create"
+		switch {
+		case existing[name] && content == "":
+			action = "delete"
+		case existing[name]:
+			action = "update"
+		case content == "":
+			continue
+		}
+
+		file := &gitlab.UpdateSnippetFileOptions{
+			Action:   gitlab.String(action),
+			FilePath: gitlab.String(name),
+		}
+		if action != "delete" {
+			file.Content = gitlab.String(content)
+		}
+		snippetFiles = append(snippetFiles, file)
+	}
+	if len(snippetFiles) == 0 {
+		return nil
+	}
+
 	opt := &gitlab.UpdateSnippetOptions{
 		Title:       gitlab.String("pet-snippet"),
-		FileName:    gitlab.String(config.Conf.GitLab.FileName),
 		Description: gitlab.String("Snippet file generated by pet"),
-		Content:     gitlab.String(content),
 		Visibility:  gitlab.Visibility(gitlab.VisibilityValue(config.Conf.GitLab.Visibility)),
+		Files:       &snippetFiles,
 	}
 
 	_, _, err = g.Client.Snippets.UpdateSnippet(g.ID, opt)
@@ -185,3 +234,15 @@ func (g GitLabClient) updateSnippet(ctx context.Context, content string) (err er
 	}
 	return nil
 }
+
+// snippetFileRef returns the branch of a snippet file from its raw URL,
+// which looks like https://gitlab.com/-/snippets/1/raw/main/pet-snippet.toml
+func snippetFileRef(rawURL, path string) string {
+	if i := strings.LastIndex(rawURL, "/raw/"); i >= 0 {
+		ref := strings.TrimSuffix(rawURL[i+len("/raw/"):], "/"+path)
+		if ref != "" && !strings.Contains(ref, "/") {
+			return ref
+		}
+	}
+	return "main"
+}
@@ -0,0 +1,165 @@
+package sync
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/knqyf263/pet/snippet"
+)
+
+const statusTimeFormat = "2006-01-02 15:04:05"
+
+// reportStatus prints when each side was last changed and synced,
+// the direction a sync would take and the snippets it would change
+func reportStatus(plans []filePlan, remote *Snippet, out io.Writer) error {
+	var local time.Time
+	for _, p := range plans {
+		if fi, err := os.Stat(p.file.local); err == nil && fi.ModTime().After(local) {
+			local = fi.ModTime()
+		}
+	}
+
+	lastSync, err := lastSyncTime()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Local:     %s\n", formatStatusTime(local, "no snippet files"))
+	fmt.Fprintf(out, "Remote:    %s\n", formatStatusTime(remote.UpdatedAt, "not created yet"))
+	fmt.Fprintf(out, "Last sync: %s\n", formatStatusTime(lastSync, "never"))
+	fmt.Fprintf(out, "Direction: %s\n", direction(plans))
+	reportChanges(plans, out)
+	return nil
+}
+
+// reportChanges prints the snippets a sync would download, upload or ask about
+func reportChanges(plans []filePlan, out io.Writer) {
+	changed := false
+	for _, p := range plans {
+		downloads, uploads := p.changes()
+		if len(downloads) == 0 && len(uploads) == 0 && len(p.conflicts) == 0 && !p.fileConflict {
+			continue
+		}
+
+		changed = true
+		fmt.Fprintln(out, p.file.name)
+		for _, c := range downloads {
+			fmt.Fprintf(out, "  download %s %s\n", c.Kind, title(c.Snippet))
+		}
+		for _, c := range uploads {
+			fmt.Fprintf(out, "  upload   %s %s\n", c.Kind, title(c.Snippet))
+		}
+		for _, c := range p.conflicts {
+			fmt.Fprintf(out, "  conflict ! %s\n", conflictTitle(c))
+		}
+		if p.fileConflict {
+			fmt.Fprintln(out, "  conflict ! the whole file, changed on both sides")
+		}
+	}
+
+	if !changed {
+		fmt.Fprintln(out, "Already up-to-date")
+	}
+}
+
+// changes returns the snippets a sync would change locally and remotely,
+// leaving out conflicting snippets whose outcome is up to the user
+func (p filePlan) changes() (downloads, uploads []snippet.Change) {
+	if p.fileConflict {
+		return nil, nil
+	}
+	conflicting := map[string]bool{}
+	for _, c := range p.conflicts {
+		for _, s := range []*snippet.SnippetInfo{c.Local, c.Remote, c.Base} {
+			if s != nil {
+				conflicting[s.ID] = true
+			}
+		}
+	}
+
+	for _, c := range snippet.Diff(p.local, p.merged) {
+		if !conflicting[c.Snippet.ID] {
+			downloads = append(downloads, c)
+		}
+	}
+	for _, c := range snippet.Diff(p.remote, p.merged) {
+		if !conflicting[c.Snippet.ID] {
+			uploads = append(uploads, c)
+		}
+	}
+	return downloads, uploads
+}
+
+// direction describes which way a sync would go
+func direction(plans []filePlan) string {
+	var download, upload, conflict bool
+	for _, p := range plans {
+		downloads, uploads := p.changes()
+		download = download || len(downloads) > 0
+		upload = upload || len(uploads) > 0
+		conflict = conflict || len(p.conflicts) > 0 || p.fileConflict
+	}
+
+	var directions []string
+	if download {
+		directions = append(directions, "download")
+	}
+	if upload {
+		directions = append(directions, "upload")
+	}
+	if conflict {
+		directions = append(directions, "resolve conflicts")
+	}
+	if len(directions) == 0 {
+		return "none"
+	}
+	return strings.Join(directions, ", ")
+}
+
+// lastSyncTime returns when the base of the configured backend was last saved
+func lastSyncTime() (last time.Time, err error) {
+	dir, err := baseDir()
+	if err != nil {
+		return last, err
+	}
+
+	names, err := baseNames()
+	if err != nil {
+		return last, err
+	}
+	for _, name := range names {
+		if fi, err := os.Stat(filepath.Join(dir, name)); err == nil && fi.ModTime().After(last) {
+			last = fi.ModTime()
+		}
+	}
+	return last, nil
+}
+
+func formatStatusTime(t time.Time, zero string) string {
+	if t.IsZero() {
+		return zero
+	}
+	return t.Local().Format(statusTimeFormat)
+}
+
+// title names a snippet by its description, or its command if it has none
+func title(s snippet.SnippetInfo) string {
+	if s.Description != "" {
+		return s.Description
+	}
+	return s.Command
+}
+
+// conflictTitle names the snippet of a conflict
+func conflictTitle(c snippet.Conflict) string {
+	for _, s := range []*snippet.SnippetInfo{c.Local, c.Remote, c.Base} {
+		if s != nil {
+			return title(*s)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,382 @@
+package sync
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/briandowns/spinner"
+	"github.com/knqyf263/pet/config"
+	"github.com/pkg/errors"
+)
+
+const (
+	s3AccessKeyEnvVariable = "AWS_ACCESS_KEY_ID"
+	s3SecretKeyEnvVariable = "AWS_SECRET_ACCESS_KEY"
+	defaultS3Region        = "us-east-1"
+)
+
+// S3Client manages communication with an S3 compatible object storage.
+// Each snippet file is an object under the key prefix.
+type S3Client struct {
+	Client          *http.Client
+	Endpoint        *url.URL
+	Bucket          string
+	Prefix          string
+	Region          string
+	PathStyle       bool
+	AccessKeyID     string
+	SecretAccessKey string
+
+	// etags are the ETags of the objects as they were read, so that
+	// uploads fail rather than overwrite objects changed in the meantime
+	etags map[string]string
+}
+
+// NewS3Client returns S3Client
+func NewS3Client() (Client, error) {
+	conf := config.Conf.S3
+	if conf.Bucket == "" {
+		return nil, errors.New(`bucket is empty.
+Write the name of the bucket to bucket in [S3] in config file (pet configure).`)
+	}
+
+	accessKeyID, secretAccessKey := getS3Credentials()
+	if accessKeyID == "" || secretAccessKey == "" {
+		return nil, fmt.Errorf(`access_key_id or secret_access_key is empty.
+Write them in config file (pet configure) or export $%v and $%v.
+		`, s3AccessKeyEnvVariable, s3SecretKeyEnvVariable)
+	}
+
+	region := conf.Region
+	if region == "" {
+		region = defaultS3Region
+	}
+
+	endpoint := conf.Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", region)
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return nil, errors.Errorf("Invalid S3 endpoint: %s", endpoint)
+	}
+
+	prefix := strings.Trim(conf.Prefix, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	return &S3Client{
+		Client:          &http.Client{Timeout: 30 * time.Second},
+		Endpoint:        u,
+		Bucket:          conf.Bucket,
+		Prefix:          prefix,
+		Region:          region,
+		PathStyle:       conf.PathStyle,
+		AccessKeyID:     accessKeyID,
+		SecretAccessKey: secretAccessKey,
+		etags:           map[string]string{},
+	}, nil
+}
+
+func getS3Credentials() (accessKeyID, secretAccessKey string) {
+	accessKeyID = config.Conf.S3.AccessKeyID
+	if accessKeyID == "" {
+		accessKeyID = os.Getenv(s3AccessKeyEnvVariable)
+	}
+	secretAccessKey = config.Conf.S3.SecretAccessKey
+	if secretAccessKey == "" {
+		secretAccessKey = os.Getenv(s3SecretKeyEnvVariable)
+	}
+	return accessKeyID, secretAccessKey
+}
+
+// s3Object is an object listed by ListObjectsV2
+type s3Object struct {
+	Key          string
+	LastModified time.Time
+	ETag         string
+}
+
+type s3ListResult struct {
+	Contents              []s3Object
+	IsTruncated           bool
+	NextContinuationToken string
+}
+
+type s3Error struct {
+	Code    string
+	Message string
+}
+
+// GetSnippet returns the snippet files stored under the key prefix
+func (c *S3Client) GetSnippet() (*Snippet, error) {
+	s := spinner.New(spinner.CharSets[14], 100*time.Millisecond)
+	s.Start()
+	s.Suffix = " Getting S3 objects..."
+	defer s.Stop()
+
+	objects, err := c.listObjects()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to list S3 objects")
+	}
+
+	snippet := &Snippet{Files: map[string]string{}}
+	for _, object := range objects {
+		name := strings.TrimPrefix(object.Key, c.Prefix)
+		res, err := c.do(http.MethodGet, object.Key, nil, nil, nil)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to get S3 object %s", object.Key)
+		}
+
+		snippet.Files[name] = string(res.body)
+		c.etags[name] = res.header.Get("ETag")
+		if object.LastModified.After(snippet.UpdatedAt) {
+			snippet.UpdatedAt = object.LastModified
+		}
+	}
+	return snippet, nil
+}
+
+// UploadSnippet writes the snippet files as objects, only if nobody else changed them since they were read
+func (c *S3Client) UploadSnippet(files map[string]string) error {
+	s := spinner.New(spinner.CharSets[14], 100*time.Millisecond)
+	s.Start()
+	s.Suffix = " Uploading S3 objects..."
+	defer s.Stop()
+
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		key := c.Prefix + name
+		etag, exists := c.etags[name]
+
+		if files[name] == "" {
+			if !exists {
+				continue
+			}
+			header := http.Header{}
+			header.Set("If-Match", etag)
+			if _, err := c.do(http.MethodDelete, key, nil, header, nil); err != nil {
+				return errors.Wrapf(err, "Failed to delete S3 object %s", key)
+			}
+			delete(c.etags, name)
+			continue
+		}
+
+		header := http.Header{}
+		if exists {
+			header.Set("If-Match", etag)
+		} else {
+			header.Set("If-None-Match", "*")
+		}
+		header.Set("Content-Type", contentType(name))
+
+		res, err := c.do(http.MethodPut, key, nil, header, []byte(files[name]))
+		if err != nil {
+			return errors.Wrapf(err, "Failed to put S3 object %s", key)
+		}
+		c.etags[name] = res.header.Get("ETag")
+	}
+	return nil
+}
+
+// listObjects lists the objects directly under the key prefix
+func (c *S3Client) listObjects() ([]s3Object, error) {
+	var objects []s3Object
+	token := ""
+	for {
+		query := url.Values{}
+		query.Set("list-type", "2")
+		query.Set("prefix", c.Prefix)
+		query.Set("delimiter", "/")
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		res, err := c.do(http.MethodGet, "", query, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var result s3ListResult
+		if err := xml.Unmarshal(res.body, &result); err != nil {
+			return nil, errors.Wrap(err, "Failed to parse the object list")
+		}
+		objects = append(objects, result.Contents...)
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return objects, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// s3Response is the part of a response pet needs
+type s3Response struct {
+	header http.Header
+	body   []byte
+}
+
+// do sends a signed request for an object, or for the bucket if key is empty
+func (c *S3Client) do(method, key string, query url.Values, header http.Header, body []byte) (*s3Response, error) {
+	u := *c.Endpoint
+	if c.PathStyle {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + c.Bucket
+		if key != "" {
+			u.Path += "/" + key
+		}
+	} else {
+		u.Host = c.Bucket + "." + u.Host
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + key
+	}
+	u.RawPath = s3URIEncode(u.Path, false)
+	u.RawQuery = s3CanonicalQuery(query)
+
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	c.sign(req, body, time.Now())
+
+	res, err := c.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	resBody, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode == http.StatusPreconditionFailed || res.StatusCode == http.StatusConflict {
+		return nil, errRemoteChanged
+	}
+	if res.StatusCode >= 300 {
+		var s3Err s3Error
+		if xml.Unmarshal(resBody, &s3Err) == nil && s3Err.Code != "" {
+			return nil, errors.Errorf("%s: %s", s3Err.Code, s3Err.Message)
+		}
+		return nil, errors.New(res.Status)
+	}
+	return &s3Response{header: res.Header, body: resBody}, nil
+}
+
+// sign signs the request with AWS Signature Version 4.
+// The host and every header set on the request are signed.
+func (c *S3Client) sign(req *http.Request, body []byte, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	var names []string
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		s3URIEncode(req.URL.Path, false),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + c.Region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+c.SecretAccessKey), date)
+	key = hmacSHA256(key, c.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.AccessKeyID, scope, signedHeaders, signature,
+	))
+}
+
+// s3CanonicalQuery encodes the query sorted by key as SigV4 expects it
+func s3CanonicalQuery(query url.Values) string {
+	var keys []string
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var params []string
+	for _, key := range keys {
+		for _, value := range query[key] {
+			params = append(params, s3URIEncode(key, true)+"="+s3URIEncode(value, true))
+		}
+	}
+	return strings.Join(params, "&")
+}
+
+// s3URIEncode percent-encodes everything but unreserved characters, and '/' unless encodeSlash
+func s3URIEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for _, c := range []byte(s) {
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -1,58 +1,80 @@
 package sync
 
 import (
+	"bufio"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/knqyf263/pet/config"
 	"github.com/knqyf263/pet/path"
 	"github.com/knqyf263/pet/snippet"
 	"github.com/pkg/errors"
+	"golang.org/x/term"
 )
 
 // Client manages communication with the remote Snippet repository
 type Client interface {
 	GetSnippet() (*Snippet, error)
-	UploadSnippet(string) error
+	// UploadSnippet creates or updates the given files, an empty content deletes the file
+	UploadSnippet(map[string]string) error
 }
 
-// Snippet is the remote snippet
+// errRemoteChanged is returned by clients which refuse to overwrite remote changes made during a sync
+var errRemoteChanged = errors.New("the remote snippets changed since they were read, sync again")
+
+// errLocalChanged is returned when the snippet files changed while a sync merged them
+var errLocalChanged = errors.New("the local snippets changed during the sync, sync again")
+
+// Snippet is the remote snippet, made of snippet files keyed by their remote name
 type Snippet struct {
-	Content   string
+	Files     map[string]string
 	UpdatedAt time.Time
 }
 
-// AutoSync syncs snippets automatically
+// syncFile is a snippet file which exists locally, remotely or in the base
+type syncFile struct {
+	name  string // name of the file in the remote repository
+	local string // path of the local file
+	dir   bool   // whether the file belongs to a snippet directory
+}
+
+// Options changes what a sync does
+type Options struct {
+	Status bool // report timestamps, direction and snippet changes without syncing
+	DryRun bool // report the snippet changes without writing anything
+	Push   bool // make the remote snippets the same as the local ones
+	Pull   bool // make the local snippets the same as the remote ones
+}
+
+// AutoSync merges the local and remote snippets with the snippets of the last sync,
+// uploading local changes and downloading remote ones
 func AutoSync(filePath path.AbsolutePath) error {
-	client, err := NewSyncClient()
-	if err != nil {
-		return errors.Wrap(err, "Failed to initialize API client")
-	}
+	return Run(Options{})
+}
 
-	snippet, err := client.GetSnippet()
-	if err != nil {
-		return err
+// Run syncs snippets with the configured backend as changed by opts
+func Run(opts Options) error {
+	if opts.Push && opts.Pull {
+		return errors.New("--push and --pull cannot be used together")
 	}
 
-	fi, err := os.Stat(filePath.Get())
-	if os.IsNotExist(err) || fi.Size() == 0 {
-		return download(snippet.Content)
-	} else if err != nil {
-		return errors.Wrap(err, "Failed to get a FileInfo")
+	client, err := NewSyncClient()
+	if err != nil {
+		return errors.Wrap(err, "Failed to initialize API client")
 	}
 
-	local := fi.ModTime().UTC()
-	remote := snippet.UpdatedAt.UTC()
-
-	switch {
-	case local.After(remote):
-		return upload(client)
-	case remote.After(local):
-		return download(snippet.Content)
-	default:
-		return nil
+	// Without a terminal, such as in a background sync, nobody can answer questions
+	var in io.Reader = os.Stdin
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		in = nil
 	}
+	return merge(client, opts, in, os.Stdout)
 }
 
 // NewSyncClient returns Client
@@ -69,6 +91,30 @@ func NewSyncClient() (Client, error) {
 			return nil, errors.Wrap(err, "Failed to initialize GHE client")
 		}
 		return client, nil
+	} else if config.Conf.General.Backend == "s3" {
+		client, err := NewS3Client()
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to initialize S3 client")
+		}
+		return client, nil
+	} else if config.Conf.General.Backend == "webdav" {
+		client, err := NewWebDAVClient()
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to initialize WebDAV client")
+		}
+		return client, nil
+	} else if config.Conf.General.Backend == "git" {
+		client, err := NewGitClient()
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to initialize git client")
+		}
+		return client, nil
+	} else if config.Conf.General.Backend == "gitea" {
+		client, err := NewGiteaClient()
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to initialize Gitea client")
+		}
+		return client, nil
 	}
 	client, err := NewGistClient()
 	if err != nil {
@@ -77,55 +123,562 @@ func NewSyncClient() (Client, error) {
 	return client, nil
 }
 
-// upload uploads snippets from the main snippet file
-// to the remote repository - directories are ignored
-func upload(client Client) (err error) {
-	var snippets snippet.Snippets
-	if err := snippets.Load(false); err != nil {
+// filePlan is what a sync does to a snippet file
+type filePlan struct {
+	file      syncFile
+	local     []snippet.SnippetInfo
+	remote    []snippet.SnippetInfo
+	merged    []snippet.SnippetInfo
+	conflicts []snippet.Conflict
+
+	localBody  string
+	remoteBody string
+	body       string
+
+	// remoteEncrypted is whether the remote file is encrypted
+	remoteEncrypted bool
+	// fileConflict is whether a read-only file changed on both sides is left as it is
+	fileConflict bool
+}
+
+func (p filePlan) download() bool { return p.body != p.localBody }
+
+// upload reports whether the remote file changes, which includes
+// encrypting or decrypting it after encryption was turned on or off
+func (p filePlan) upload() bool {
+	return !p.fileConflict && (p.body != p.remoteBody ||
+		p.remoteBody != "" && p.remoteEncrypted != config.Conf.Encryption.Sync)
+}
+
+// merge performs a three-way merge of each local snippet file, the remote snippets
+// and the base saved by the previous sync. Conflicts are resolved by asking the user,
+// or by keeping both versions if in is nil.
+// With opts.Push or opts.Pull one side replaces the other instead.
+func merge(client Client, opts Options, in io.Reader, out io.Writer) error {
+	remote, err := client.GetSnippet()
+	if err != nil {
+		return err
+	}
+
+	// A preview leaves the local files as they are, backfilled IDs included
+	preview := opts.Status || opts.DryRun
+	var local snippet.Snippets
+	load := local.Load
+	if preview {
+		load = local.LoadWithoutSaving
+	}
+
+	// The files are read under the lock, which is released while conflicts are resolved
+	unlock, err := snippet.Lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := load(true); err != nil {
 		return errors.Wrap(err, "Failed to load the local snippets")
 	}
+	files, err := syncFiles(remote)
+	if err != nil {
+		return err
+	}
+	loaded, err := readState(files)
+	if err != nil {
+		return err
+	}
+	unlock()
+
+	var reader *bufio.Reader
+	if in != nil {
+		reader = bufio.NewReader(in)
+	}
+	var plans []filePlan
+	for _, f := range files {
+		if snippet.IsReadOnly(f.local) {
+			plan, err := planReadOnly(f, remote, opts, preview, reader, out)
+			if err != nil {
+				return err
+			}
+			plans = append(plans, plan)
+			continue
+		}
+
+		plan := filePlan{file: f}
+		for _, s := range local.Snippets {
+			if s.Filename == f.local || !f.dir && s.Filename == config.Conf.General.SnippetFile {
+				plan.local = append(plan.local, s)
+			}
+		}
+
+		base, err := loadBase(f.name)
+		if err != nil {
+			return err
+		}
+		// Each file is synced in the format of the local file
+		format := snippet.FormatOf(f.local)
+		baseSnippets, err := format.Decode([]byte(base))
+		if err != nil {
+			return errors.Wrapf(err, "Failed to parse the last synced %s", f.name)
+		}
+		remoteContent := []byte(remote.Files[f.name])
+		plan.remoteEncrypted = snippet.IsEncrypted(remoteContent)
+		if remoteContent, err = snippet.Decrypt(remoteContent); err != nil {
+			return errors.Wrapf(err, "Failed to decrypt the remote %s", f.name)
+		}
+		if plan.remote, err = format.Decode(remoteContent); err != nil {
+			return errors.Wrapf(err, "Failed to parse the remote %s", f.name)
+		}
+
+		switch {
+		case opts.Push:
+			plan.merged = plan.local
+		case opts.Pull:
+			plan.merged = plan.remote
+		default:
+			plan.merged, plan.conflicts = snippet.Merge(baseSnippets, plan.local, plan.remote)
+		}
+
+		// A preview only reports the conflicts
+		if !preview {
+			resolved, err := resolveConflicts(f, plan.conflicts, reader, out)
+			if err != nil {
+				return err
+			}
+			plan.merged = append(plan.merged, resolved...)
+		}
+
+		if plan.localBody, err = toString(format, plan.local); err != nil {
+			return err
+		}
+		if plan.remoteBody, err = toString(format, plan.remote); err != nil {
+			return err
+		}
+		if plan.body, err = toString(format, plan.merged); err != nil {
+			return err
+		}
+		plans = append(plans, plan)
+	}
+
+	if opts.Status {
+		return reportStatus(plans, remote, out)
+	}
+	if opts.DryRun {
+		reportChanges(plans, out)
+		return nil
+	}
 
-	body, err := snippets.ToString()
+	// Nothing is written before every conflict is resolved, and nothing at all if another
+	// pet changed the files since they were merged
+	unlock, err = snippet.Lock()
 	if err != nil {
 		return err
 	}
+	defer unlock()
+	current, err := readState(files)
+	if err != nil {
+		return err
+	}
+	if !reflect.DeepEqual(loaded, current) {
+		return errLocalChanged
+	}
+
+	downloaded := false
+	uploads := map[string]string{}
+	for _, p := range plans {
+		if p.download() {
+			if err := download(p.file, p.body); err != nil {
+				return errors.Wrap(err, "Failed to save the merged snippets")
+			}
+			downloaded = true
+		}
+		if p.upload() {
+			body, err := encryptUpload(p.body)
+			if err != nil {
+				return errors.Wrapf(err, "Failed to encrypt %s", p.file.name)
+			}
+			uploads[p.file.name] = body
+		}
+	}
+
+	if len(uploads) > 0 {
+		if err := client.UploadSnippet(uploads); err != nil {
+			return errors.Wrap(err, "Failed to upload snippet")
+		}
+	}
 
-	if err = client.UploadSnippet(body); err != nil {
-		return errors.Wrap(err, "Failed to upload snippet")
+	for _, p := range plans {
+		// A file left in conflict stays in conflict until one side is chosen
+		if p.fileConflict {
+			continue
+		}
+		if err := saveBase(p.file.name, p.body); err != nil {
+			return err
+		}
 	}
 
-	fmt.Println("Upload success")
+	switch {
+	case downloaded && len(uploads) > 0:
+		fmt.Fprintln(out, "Sync success")
+	case downloaded:
+		fmt.Fprintln(out, "Download success")
+	case len(uploads) > 0:
+		fmt.Fprintln(out, "Upload success")
+	default:
+		fmt.Fprintln(out, "Already up-to-date")
+	}
 	return nil
 }
 
-// download downloads snippets from the remote repository
-// and saves them to the main snippet file - directories ignored
-func download(content string) error {
-	var snippets snippet.Snippets
-	if err := snippets.Load(false); err != nil {
-		return err
+// syncFiles lists the main snippet file, the files of the snippet directories
+// and the remote or previously synced files belonging to one of the directories
+func syncFiles(remote *Snippet) ([]syncFile, error) {
+	mainFile, err := path.NewAbsolutePath(config.Conf.General.SnippetFile)
+	if err != nil {
+		return nil, err
 	}
+	files := []syncFile{{name: mainFileName(), local: mainFile.Get()}}
 
-	body, err := snippets.ToString()
+	dirs, err := snippetDirs()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if content == body {
-		// no need to download
-		fmt.Println("Already up-to-date")
-		return nil
+	seen := map[string]bool{files[0].name: true}
+	for _, dir := range dirs {
+		for _, file := range snippet.GetFiles(dir.path) {
+			name, err := dir.remoteName(file)
+			if err != nil {
+				return nil, err
+			}
+			if !seen[name] {
+				seen[name] = true
+				files = append(files, syncFile{name: name, local: file, dir: true})
+			}
+		}
 	}
 
-	fmt.Println("Download success")
-	absPath, err := path.NewAbsolutePath(config.Conf.General.SnippetFile)
+	bases, err := baseNames()
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for name := range remote.Files {
+		names = append(names, name)
+	}
+	names = append(names, bases...)
+	sort.Strings(names)
+
+	for _, name := range names {
+		if seen[name] {
+			continue
+		}
+		// Remote files which are not snippet files of pet are left alone, and so are
+		// local files skipped for holding no snippets
+		if file, ok := localFile(dirs, name); ok {
+			if _, err := os.Stat(file); err == nil {
+				continue
+			}
+			seen[name] = true
+			files = append(files, syncFile{name: name, local: file, dir: true})
+		}
+	}
+	return files, nil
+}
+
+// readState returns the contents of the local files of a sync and of the files of the last
+// sync, by path. Files which don't exist are left out.
+func readState(files []syncFile) (map[string]string, error) {
+	dir, err := baseDir()
+	if err != nil {
+		return nil, err
+	}
+
+	state := map[string]string{}
+	for _, f := range files {
+		for _, file := range []string{f.local, filepath.Join(dir, f.name)} {
+			content, err := os.ReadFile(file)
+			if os.IsNotExist(err) {
+				continue
+			} else if err != nil {
+				return nil, errors.Wrapf(err, "Failed to read %s", file)
+			}
+			state[file] = string(content)
+		}
+	}
+	return state, nil
+}
+
+// encryptUpload encrypts the content of a remote file if encryption of sync is enabled
+func encryptUpload(content string) (string, error) {
+	if content == "" || !config.Conf.Encryption.Sync {
+		return content, nil
+	}
+	encrypted, err := snippet.Encrypt([]byte(content))
+	return string(encrypted), err
+}
+
+func toString(format snippet.Format, snippets []snippet.SnippetInfo) (string, error) {
+	data, err := format.Encode(snippets)
+	if err != nil {
+		return "", errors.Wrap(err, "Failed to encode snippets")
+	}
+	return string(data), nil
+}
+
+// planReadOnly plans the sync of a read-only file such as a Markdown cheatsheet. pet cannot
+// write its snippets back, so the file is synced as a whole: the side which changed it since
+// the last sync wins. Which version of a file changed on both sides to keep is asked,
+// without a reader to ask the file is left as it is on both sides.
+func planReadOnly(f syncFile, remote *Snippet, opts Options, preview bool, reader *bufio.Reader, out io.Writer) (filePlan, error) {
+	plan := filePlan{file: f}
+
+	localContent, err := snippet.ReadFile(f.local)
+	if err != nil && !os.IsNotExist(err) {
+		return plan, errors.Wrapf(err, "Failed to read %s", f.local)
+	}
+	plan.localBody = string(localContent)
+
+	base, err := loadBase(f.name)
+	if err != nil {
+		return plan, err
+	}
+	remoteContent := []byte(remote.Files[f.name])
+	plan.remoteEncrypted = snippet.IsEncrypted(remoteContent)
+	if remoteContent, err = snippet.Decrypt(remoteContent); err != nil {
+		return plan, errors.Wrapf(err, "Failed to decrypt the remote %s", f.name)
+	}
+	plan.remoteBody = string(remoteContent)
+
+	switch {
+	case opts.Push:
+		plan.body = plan.localBody
+	case opts.Pull:
+		plan.body = plan.remoteBody
+	case plan.localBody == plan.remoteBody || plan.remoteBody == base:
+		plan.body = plan.localBody
+	case plan.localBody == base:
+		plan.body = plan.remoteBody
+	case preview || reader == nil:
+		if !preview {
+			fmt.Fprintf(out, "Conflict: %s changed on both sides, left as it is. Run pet sync --push or --pull to choose one\n", f.name)
+		}
+		plan.body = plan.localBody
+		plan.fileConflict = true
+	default:
+		keepLocal, err := resolveFileConflict(f, reader, out)
+		if err != nil {
+			return plan, err
+		}
+		plan.body = plan.remoteBody
+		if keepLocal {
+			plan.body = plan.localBody
+		}
+	}
+
+	// The snippets of each version tell what the sync changes
+	format := snippet.FormatOf(f.local)
+	for _, v := range []struct {
+		body     string
+		snippets *[]snippet.SnippetInfo
+		side     string
+	}{
+		{plan.localBody, &plan.local, "local"},
+		{plan.remoteBody, &plan.remote, "remote"},
+		{plan.body, &plan.merged, "merged"},
+	} {
+		if *v.snippets, err = format.Decode([]byte(v.body)); err != nil {
+			return plan, errors.Wrapf(err, "Failed to parse the %s %s", v.side, f.name)
+		}
+	}
+	return plan, nil
+}
+
+// resolveFileConflict asks whether to keep the local or the remote version of a read-only file
+func resolveFileConflict(f syncFile, reader *bufio.Reader, out io.Writer) (keepLocal bool, err error) {
+	fmt.Fprintf(out, "Conflict: %s changed on both sides, pet cannot merge it\n", f.name)
+	for {
+		fmt.Fprint(out, "Keep [l]ocal or [r]emote? ")
+		line, err := reader.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			return false, errors.New("Conflicts are left unresolved, nothing was synced")
+		}
+
+		switch strings.ToLower(strings.TrimSpace(line)) {
+		case "l", "local":
+			return true, nil
+		case "r", "remote":
+			return false, nil
+		}
+	}
+}
+
+// resolveConflicts asks which version of each conflicting snippet to keep
+// and returns the kept snippets. Without a reader to ask, both versions are kept.
+func resolveConflicts(f syncFile, conflicts []snippet.Conflict, reader *bufio.Reader, out io.Writer) (kept []snippet.SnippetInfo, err error) {
+	for _, c := range conflicts {
+		if reader == nil {
+			fmt.Fprintf(out, "Conflict: %s (%s), kept both versions\n", conflictTitle(c), f.name)
+			kept = append(kept, keepBoth(c)...)
+			continue
+		}
+
+		fmt.Fprintf(out, "Conflict: %s (%s)\n", conflictTitle(c), f.name)
+		fmt.Fprintf(out, "--- local\n%s", conflictVersion(c.Local))
+		fmt.Fprintf(out, "--- remote\n%s", conflictVersion(c.Remote))
+
+		prompt := "Keep [l]ocal or [r]emote? "
+		if c.Local != nil && c.Remote != nil {
+			prompt = "Keep [l]ocal, [r]emote or [b]oth? "
+		}
+
+		for answered := false; !answered; {
+			fmt.Fprint(out, prompt)
+			line, err := reader.ReadString('\n')
+			if err != nil && (err != io.EOF || line == "") {
+				return nil, errors.New("Conflicts are left unresolved, nothing was synced")
+			}
+
+			answered = true
+			switch strings.ToLower(strings.TrimSpace(line)) {
+			case "l", "local":
+				if c.Local != nil {
+					kept = append(kept, *c.Local)
+				}
+			case "r", "remote":
+				if c.Remote != nil {
+					kept = append(kept, *c.Remote)
+				}
+			case "b", "both":
+				if c.Local == nil || c.Remote == nil {
+					answered = false
+					continue
+				}
+				kept = append(kept, keepBoth(c)...)
+			default:
+				answered = false
+			}
+		}
+	}
+	return kept, nil
+}
+
+// keepBoth returns the versions of a conflicting snippet which were not deleted.
+// The remote version of a snippet changed on both sides becomes a snippet of its own.
+func keepBoth(c snippet.Conflict) (kept []snippet.SnippetInfo) {
+	if c.Local != nil {
+		kept = append(kept, *c.Local)
+	}
+	if c.Remote != nil {
+		remote := *c.Remote
+		if c.Local != nil {
+			remote.ID = snippet.NewID()
+		}
+		kept = append(kept, remote)
+	}
+	return kept
+}
+
+// conflictVersion renders one side of a conflict
+func conflictVersion(s *snippet.SnippetInfo) string {
+	if s == nil {
+		return "(deleted)\n"
+	}
+	body, err := (&snippet.Snippets{Snippets: []snippet.SnippetInfo{*s}}).ToString()
+	if err != nil {
+		return fmt.Sprintf("%+v\n", *s)
+	}
+	return body
+}
+
+// baseDir returns the directory keeping the snippet files as of the last sync with the backend
+func baseDir() (string, error) {
+	dir, err := config.GetDefaultConfigDir()
+	if err != nil {
+		return "", errors.Wrap(err, "Failed to get the default config directory")
+	}
+
+	backend := config.Conf.General.Backend
+	if backend == "" {
+		backend = "gist"
+	}
+	return filepath.Join(dir, "sync", backend), nil
+}
+
+// baseNames returns the names of the files of the last sync
+func baseNames() ([]string, error) {
+	dir, err := baseDir()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "Failed to read the snippets of the last sync")
+	}
+
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names, nil
+}
+
+// loadBase returns a snippet file as of the last sync, nothing if it was not synced
+func loadBase(name string) (string, error) {
+	dir, err := baseDir()
+	if err != nil {
+		return "", err
+	}
+
+	content, err := snippet.ReadFile(filepath.Join(dir, name))
+	if os.IsNotExist(err) {
+		return "", nil
+	} else if err != nil {
+		return "", errors.Wrap(err, "Failed to read the snippets of the last sync")
+	}
+	return string(content), nil
+}
+
+// saveBase keeps a synced snippet file as the base of the next sync
+func saveBase(name string, content string) error {
+	dir, err := baseDir()
 	if err != nil {
 		return err
 	}
+	file := filepath.Join(dir, name)
+
+	if content == "" {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return errors.Wrap(err, "Failed to remove the snippets of the last sync")
+		}
+		return nil
+	}
 
-	return os.WriteFile(
-		absPath.Get(),
-		[]byte(content),
-		os.ModePerm,
-	)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return errors.Wrap(err, "Failed to create the sync directory")
+	}
+	if err := snippet.WriteCopy(file, []byte(content), 0600); err != nil {
+		return errors.Wrap(err, "Failed to save the snippets of the last sync")
+	}
+	return nil
+}
+
+// download saves the merged snippets to the local snippet file.
+// Files of snippet directories left without snippets are deleted, keeping them as .bak files.
+func download(f syncFile, content string) error {
+	if f.dir && content == "" {
+		if err := os.Rename(f.local, f.local+".bak"); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return snippet.RecordDeletion(f.local, snippet.ReasonSync)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(f.local), 0700); err != nil {
+		return err
+	}
+	if err := snippet.WriteFile(f.local, []byte(content), 0666); err != nil {
+		return err
+	}
+	return snippet.RecordVersion(f.local, []byte(content), snippet.ReasonSync)
 }
@@ -0,0 +1,586 @@
+package sync
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/knqyf263/pet/config"
+	"github.com/knqyf263/pet/snippet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryClient is a remote repository kept in memory
+type memoryClient struct {
+	files   map[string]string
+	uploads int
+}
+
+func newMemoryClient() *memoryClient {
+	return &memoryClient{files: map[string]string{}}
+}
+
+func (c *memoryClient) GetSnippet() (*Snippet, error) {
+	files := map[string]string{}
+	for name, content := range c.files {
+		files[name] = content
+	}
+	return &Snippet{Files: files}, nil
+}
+
+func (c *memoryClient) UploadSnippet(files map[string]string) error {
+	for name, content := range files {
+		if content == "" {
+			delete(c.files, name)
+		} else {
+			c.files[name] = content
+		}
+	}
+	c.uploads++
+	return nil
+}
+
+func setupMergeTest(t *testing.T, local string) string {
+	dir := t.TempDir()
+	t.Setenv("PET_CONFIG_DIR", dir)
+
+	orig := config.Conf
+	t.Cleanup(func() { config.Conf = orig })
+
+	snippetFile := filepath.Join(dir, "snippet.toml")
+	require.NoError(t, os.WriteFile(snippetFile, []byte(local), 0644))
+	config.Conf = config.Config{}
+	config.Conf.General.SnippetFile = snippetFile
+	config.Conf.General.StateFile = filepath.Join(dir, "state.toml")
+	return snippetFile
+}
+
+func snippetsOf(t *testing.T, content string) (commands []string) {
+	snippets, err := snippet.Parse(content)
+	require.NoError(t, err)
+	for _, s := range snippets {
+		commands = append(commands, s.Command)
+	}
+	return commands
+}
+
+const (
+	lsSnippet = "[[Snippets]]\n  id = \"1\"\n  Description = \"list\"\n  command = \"ls\"\n"
+	psSnippet = "[[Snippets]]\n  id = \"2\"\n  Description = \"processes\"\n  command = \"ps\"\n"
+	dfSnippet = "[[Snippets]]\n  id = \"3\"\n  Description = \"disk\"\n  command = \"df\"\n"
+)
+
+func TestMergeUploadsToNewRemote(t *testing.T) {
+	setupMergeTest(t, lsSnippet)
+	client := newMemoryClient()
+
+	var out bytes.Buffer
+	require.NoError(t, merge(client, Options{}, strings.NewReader(""), &out))
+	assert.Equal(t, []string{"ls"}, snippetsOf(t, client.files[defaultRemoteFileName]))
+	assert.Equal(t, "Upload success\n", out.String())
+
+	// Nothing changed since
+	out.Reset()
+	require.NoError(t, merge(client, Options{}, strings.NewReader(""), &out))
+	assert.Equal(t, 1, client.uploads)
+	assert.Equal(t, "Already up-to-date\n", out.String())
+}
+
+func TestMergeKeepsChangesOfBothSides(t *testing.T) {
+	snippetFile := setupMergeTest(t, lsSnippet+psSnippet)
+	client := newMemoryClient()
+	require.NoError(t, merge(client, Options{}, strings.NewReader(""), &bytes.Buffer{}))
+
+	// The local file deletes ps while the remote adds df
+	require.NoError(t, os.WriteFile(snippetFile, []byte(lsSnippet), 0644))
+	client.files[defaultRemoteFileName] = lsSnippet + psSnippet + dfSnippet
+
+	var out bytes.Buffer
+	require.NoError(t, merge(client, Options{}, strings.NewReader(""), &out))
+	assert.Equal(t, "Sync success\n", out.String())
+
+	local, err := os.ReadFile(snippetFile)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ls", "df"}, snippetsOf(t, string(local)))
+	assert.Equal(t, []string{"ls", "df"}, snippetsOf(t, client.files[defaultRemoteFileName]))
+
+	// The download can be undone
+	journal, err := snippet.LoadJournal()
+	require.NoError(t, err)
+	require.NotEmpty(t, journal.Versions)
+	last := journal.Versions[len(journal.Versions)-1]
+	assert.Equal(t, snippet.ReasonSync, last.Reason)
+	diff, err := journal.Diff(last)
+	require.NoError(t, err)
+	require.Len(t, diff.Added, 1)
+	assert.Equal(t, "df", diff.Added[0].Command)
+}
+
+func TestMergeResolvesConflicts(t *testing.T) {
+	tests := []struct {
+		answer   string
+		commands []string
+	}{
+		{answer: "l\n", commands: []string{"ls -l"}},
+		{answer: "r\n", commands: []string{"ls -a"}},
+		{answer: "x\nboth\n", commands: []string{"ls -l", "ls -a"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.TrimSpace(tt.answer), func(t *testing.T) {
+			snippetFile := setupMergeTest(t, lsSnippet)
+			client := newMemoryClient()
+			require.NoError(t, merge(client, Options{}, strings.NewReader(""), &bytes.Buffer{}))
+
+			require.NoError(t, os.WriteFile(snippetFile, []byte(strings.Replace(lsSnippet, `"ls"`, `"ls -l"`, 1)), 0644))
+			client.files[defaultRemoteFileName] = strings.Replace(lsSnippet, `"ls"`, `"ls -a"`, 1)
+
+			var out bytes.Buffer
+			require.NoError(t, merge(client, Options{}, strings.NewReader(tt.answer), &out))
+			assert.Contains(t, out.String(), "Conflict: list (pet-snippet.toml)")
+
+			local, err := os.ReadFile(snippetFile)
+			require.NoError(t, err)
+			assert.Equal(t, tt.commands, snippetsOf(t, string(local)))
+			assert.Equal(t, tt.commands, snippetsOf(t, client.files[defaultRemoteFileName]))
+		})
+	}
+}
+
+func TestMergeLeavesUnresolvedConflicts(t *testing.T) {
+	snippetFile := setupMergeTest(t, lsSnippet)
+	client := newMemoryClient()
+	require.NoError(t, merge(client, Options{}, strings.NewReader(""), &bytes.Buffer{}))
+
+	edited := strings.Replace(lsSnippet, `"ls"`, `"ls -l"`, 1)
+	require.NoError(t, os.WriteFile(snippetFile, []byte(edited), 0644))
+	remote := strings.Replace(lsSnippet, `"ls"`, `"ls -a"`, 1)
+	client.files[defaultRemoteFileName] = remote
+
+	assert.Error(t, merge(client, Options{}, strings.NewReader(""), &bytes.Buffer{}))
+
+	local, err := os.ReadFile(snippetFile)
+	require.NoError(t, err)
+	assert.Equal(t, edited, string(local))
+	assert.Equal(t, remote, client.files[defaultRemoteFileName])
+}
+
+// answerReader answers a question after running meanwhile
+type answerReader struct {
+	meanwhile func()
+	answer    *strings.Reader
+}
+
+func (r *answerReader) Read(p []byte) (int, error) {
+	if r.meanwhile != nil {
+		r.meanwhile()
+		r.meanwhile = nil
+	}
+	return r.answer.Read(p)
+}
+
+func TestMergeKeepsChangesMadeDuringConflicts(t *testing.T) {
+	snippetFile := setupMergeTest(t, lsSnippet)
+	client := newMemoryClient()
+	require.NoError(t, merge(client, Options{}, strings.NewReader(""), &bytes.Buffer{}))
+
+	require.NoError(t, os.WriteFile(snippetFile, []byte(strings.Replace(lsSnippet, `"ls"`, `"ls -l"`, 1)), 0644))
+	remote := strings.Replace(lsSnippet, `"ls"`, `"ls -a"`, 1)
+	client.files[defaultRemoteFileName] = remote
+
+	// Another pet saves a snippet while the conflict waits for an answer
+	changed := strings.Replace(lsSnippet, `"ls"`, `"ls -l"`, 1) + psSnippet
+	in := &answerReader{
+		meanwhile: func() {
+			unlock, err := snippet.Lock()
+			require.NoError(t, err)
+			defer unlock()
+			require.NoError(t, snippet.WriteFile(snippetFile, []byte(changed), 0644))
+		},
+		answer: strings.NewReader("r\n"),
+	}
+	err := merge(client, Options{}, in, &bytes.Buffer{})
+	assert.ErrorIs(t, err, errLocalChanged)
+
+	local, err := os.ReadFile(snippetFile)
+	require.NoError(t, err)
+	assert.Equal(t, changed, string(local))
+	assert.Equal(t, remote, client.files[defaultRemoteFileName])
+
+	// The next sync merges the change
+	require.NoError(t, merge(client, Options{}, strings.NewReader("r\n"), &bytes.Buffer{}))
+	local, err = os.ReadFile(snippetFile)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ps", "ls -a"}, snippetsOf(t, string(local)))
+}
+
+func TestMergeWithoutTerminalKeepsBothVersions(t *testing.T) {
+	snippetFile := setupMergeTest(t, lsSnippet+psSnippet)
+	client := newMemoryClient()
+	require.NoError(t, merge(client, Options{}, strings.NewReader(""), &bytes.Buffer{}))
+
+	// ls is changed on both sides, ps is deleted locally and changed remotely
+	require.NoError(t, os.WriteFile(snippetFile, []byte(strings.Replace(lsSnippet, `"ls"`, `"ls -l"`, 1)), 0644))
+	client.files[defaultRemoteFileName] = strings.Replace(lsSnippet+psSnippet, `"ls"`, `"ls -a"`, 1)
+	client.files[defaultRemoteFileName] = strings.Replace(client.files[defaultRemoteFileName], `"ps"`, `"ps aux"`, 1)
+
+	var out bytes.Buffer
+	require.NoError(t, merge(client, Options{}, nil, &out))
+	assert.Contains(t, out.String(), "Conflict: list (pet-snippet.toml), kept both versions")
+
+	local, err := os.ReadFile(snippetFile)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"ls -l", "ls -a", "ps aux"}, snippetsOf(t, string(local)))
+	assert.ElementsMatch(t, []string{"ls -l", "ls -a", "ps aux"}, snippetsOf(t, client.files[defaultRemoteFileName]))
+}
+
+func TestMergeUpgradeOfTwoMachines(t *testing.T) {
+	// Both machines hold the same snippet, written before snippets had IDs
+	legacy := "[[Snippets]]\n  Description = \"list\"\n  command = \"ls\"\n"
+	fileA := setupMergeTest(t, legacy)
+	dirB := t.TempDir()
+	fileB := filepath.Join(dirB, "snippet.toml")
+	writeFile(t, fileB, legacy)
+	client := newMemoryClient()
+
+	// Each machine gives the snippet an ID of its own before its first sync
+	require.NoError(t, merge(client, Options{}, strings.NewReader(""), &bytes.Buffer{}))
+	t.Setenv("PET_CONFIG_DIR", dirB)
+	config.Conf.General.SnippetFile = fileB
+	config.Conf.General.StateFile = filepath.Join(dirB, "state.toml")
+	require.NoError(t, merge(client, Options{}, strings.NewReader(""), &bytes.Buffer{}))
+
+	assert.Equal(t, []string{"ls"}, snippetsOf(t, readFile(t, fileB)))
+	assert.Equal(t, []string{"ls"}, snippetsOf(t, client.files[defaultRemoteFileName]))
+	remote, err := snippet.Parse(client.files[defaultRemoteFileName])
+	require.NoError(t, err)
+	local, err := snippet.Parse(readFile(t, fileA))
+	require.NoError(t, err)
+	assert.Equal(t, local[0].ID, remote[0].ID)
+}
+
+func TestMergeSnippetDirs(t *testing.T) {
+	setupMergeTest(t, lsSnippet)
+	dir := filepath.Join(t.TempDir(), "team")
+	config.Conf.General.SnippetDirs = []string{dir}
+	writeFile(t, filepath.Join(dir, "docker.toml"), psSnippet)
+	writeFile(t, filepath.Join(dir, "k8s", "pods.toml"), dfSnippet)
+
+	client := newMemoryClient()
+	require.NoError(t, merge(client, Options{}, strings.NewReader(""), &bytes.Buffer{}))
+	assert.Equal(t, []string{"ls"}, snippetsOf(t, client.files["pet-snippet.toml"]))
+	assert.Equal(t, []string{"ps"}, snippetsOf(t, client.files["team__docker.toml"]))
+	assert.Equal(t, []string{"df"}, snippetsOf(t, client.files["team__k8s__pods.toml"]))
+
+	// Files added and deleted remotely are added and deleted locally
+	client.files["team__git.toml"] = "[[Snippets]]\n  id = \"4\"\n  command = \"git status\"\n"
+	delete(client.files, "team__k8s__pods.toml")
+	// Unknown remote files are left alone
+	client.files["notes.txt"] = "notes"
+
+	require.NoError(t, merge(client, Options{}, strings.NewReader(""), &bytes.Buffer{}))
+	assert.Equal(t, []string{"git status"}, snippetsOf(t, readFile(t, filepath.Join(dir, "git.toml"))))
+	assert.NoFileExists(t, filepath.Join(dir, "k8s", "pods.toml"))
+	assert.Equal(t, []string{"df"}, snippetsOf(t, readFile(t, filepath.Join(dir, "k8s", "pods.toml.bak"))))
+	assert.Equal(t, "notes", client.files["notes.txt"])
+
+	// Files deleted locally are deleted remotely
+	require.NoError(t, os.Remove(filepath.Join(dir, "docker.toml")))
+	require.NoError(t, merge(client, Options{}, strings.NewReader(""), &bytes.Buffer{}))
+	assert.NotContains(t, client.files, "team__docker.toml")
+	assert.Contains(t, client.files, "team__git.toml")
+}
+
+func TestMergeSnippetDirFormats(t *testing.T) {
+	setupMergeTest(t, lsSnippet)
+	dir := filepath.Join(t.TempDir(), "team")
+	config.Conf.General.SnippetDirs = []string{dir}
+	yamlFile := filepath.Join(dir, "docker.yaml")
+	writeFile(t, yamlFile, "snippets:\n  - id: \"2\"\n    description: processes\n    command: ps\n")
+
+	client := newMemoryClient()
+	require.NoError(t, merge(client, Options{}, strings.NewReader(""), &bytes.Buffer{}))
+	assert.Equal(t, "snippets:\n  - id: \"2\"\n    description: processes\n    command: ps\n", client.files["team__docker.yaml"])
+
+	// Remote changes are merged in the format of the file
+	client.files["team__docker.yaml"] = "snippets:\n  - id: \"2\"\n    description: processes\n    command: ps aux\n"
+	client.files["team__git.json"] = `{"snippets": [{"id": "4", "description": "status", "command": "git status"}]}`
+	require.NoError(t, merge(client, Options{}, strings.NewReader(""), &bytes.Buffer{}))
+	assert.Equal(t, "snippets:\n  - id: \"2\"\n    description: processes\n    command: ps aux\n", readFile(t, yamlFile))
+	assert.Contains(t, readFile(t, filepath.Join(dir, "git.json")), `"command": "git status"`)
+
+	// Files of other tools are neither uploaded nor replaced
+	compose := "services:\n  web:\n    image: nginx\n"
+	writeFile(t, filepath.Join(dir, "compose.yml"), compose)
+	require.NoError(t, merge(client, Options{}, strings.NewReader(""), &bytes.Buffer{}))
+	assert.NotContains(t, client.files, "team__compose.yml")
+	client.files["team__compose.yml"] = "snippets:\n  - id: \"5\"\n    command: uptime\n"
+	require.NoError(t, merge(client, Options{}, strings.NewReader(""), &bytes.Buffer{}))
+	assert.Equal(t, compose, readFile(t, filepath.Join(dir, "compose.yml")))
+}
+
+func TestMergeMarkdownFiles(t *testing.T) {
+	setupMergeTest(t, lsSnippet)
+	dir := filepath.Join(t.TempDir(), "team")
+	config.Conf.General.SnippetDirs = []string{dir}
+	runbook := filepath.Join(dir, "deploy.md")
+	v1 := "# Restart\r\n```sh\nsystemctl restart app\n```\n"
+	writeFile(t, runbook, v1)
+
+	// Markdown files are uploaded as they are
+	client := newMemoryClient()
+	require.NoError(t, merge(client, Options{}, strings.NewReader(""), &bytes.Buffer{}))
+	assert.Equal(t, v1, client.files["team__deploy.md"])
+
+	// A remote change replaces the local file
+	v2 := v1 + "# Logs\n```sh\njournalctl -fu app\n```\n"
+	client.files["team__deploy.md"] = v2
+	var out bytes.Buffer
+	require.NoError(t, merge(client, Options{DryRun: true}, strings.NewReader(""), &out))
+	assert.Equal(t, "team__deploy.md\n  download + Logs\n", out.String())
+	require.NoError(t, merge(client, Options{}, strings.NewReader(""), &bytes.Buffer{}))
+	assert.Equal(t, v2, readFile(t, runbook))
+
+	// A local change is uploaded
+	v3 := v2 + "# Status\n```sh\nsystemctl status app\n```\n"
+	writeFile(t, runbook, v3)
+	require.NoError(t, merge(client, Options{}, strings.NewReader(""), &bytes.Buffer{}))
+	assert.Equal(t, v3, client.files["team__deploy.md"])
+
+	// Changes on both sides are not merged, the chosen side wins
+	writeFile(t, runbook, v3+"local\n")
+	client.files["team__deploy.md"] = v3 + "remote\n"
+	out.Reset()
+	require.NoError(t, merge(client, Options{Status: true}, strings.NewReader(""), &out))
+	assert.Contains(t, out.String(), "  conflict ! the whole file, changed on both sides\n")
+	require.NoError(t, merge(client, Options{}, strings.NewReader("r\n"), &bytes.Buffer{}))
+	assert.Equal(t, v3+"remote\n", readFile(t, runbook))
+	assert.Equal(t, v3+"remote\n", client.files["team__deploy.md"])
+
+	// Without a terminal both sides are left as they are, until one is chosen
+	writeFile(t, runbook, v3+"local\n")
+	client.files["team__deploy.md"] = v3 + "remote 2\n"
+	out.Reset()
+	require.NoError(t, merge(client, Options{}, nil, &out))
+	assert.Contains(t, out.String(), "team__deploy.md changed on both sides, left as it is")
+	assert.Equal(t, v3+"local\n", readFile(t, runbook))
+	assert.Equal(t, v3+"remote 2\n", client.files["team__deploy.md"])
+	require.NoError(t, merge(client, Options{Push: true}, nil, &bytes.Buffer{}))
+	assert.Equal(t, v3+"local\n", client.files["team__deploy.md"])
+
+	// Files deleted locally are deleted remotely
+	require.NoError(t, os.Remove(runbook))
+	require.NoError(t, merge(client, Options{}, strings.NewReader(""), &bytes.Buffer{}))
+	assert.NotContains(t, client.files, "team__deploy.md")
+}
+
+func TestLocalFile(t *testing.T) {
+	dirs := []snippetDir{{path: "/snippets/team", name: "team"}}
+
+	file, ok := localFile(dirs, "team__k8s__pods.toml")
+	assert.True(t, ok)
+	assert.Equal(t, filepath.Join("/snippets/team", "k8s", "pods.toml"), file)
+
+	name, err := dirs[0].remoteName(file)
+	assert.NoError(t, err)
+	assert.Equal(t, "team__k8s__pods.toml", name)
+
+	_, ok = localFile(dirs, "pet-snippet.toml")
+	assert.False(t, ok)
+	_, ok = localFile(dirs, "other__pods.toml")
+	assert.False(t, ok)
+	_, ok = localFile(dirs, "team__notes.txt")
+	assert.False(t, ok)
+	_, ok = localFile(dirs, "team___pods.toml")
+	assert.False(t, ok)
+
+	file, ok = localFile(dirs, "team__pods.yml")
+	assert.True(t, ok)
+	assert.Equal(t, filepath.Join("/snippets/team", "pods.yml"), file)
+	file, ok = localFile(dirs, "team__runbook.md")
+	assert.True(t, ok)
+	assert.Equal(t, filepath.Join("/snippets/team", "runbook.md"), file)
+}
+
+func TestRemoteNameRoundTrip(t *testing.T) {
+	dirs := []snippetDir{{path: "/snippets/my_team", name: "my_team"}}
+
+	for _, rel := range []string{"docker_compose.toml", filepath.Join("k8s_prod", "pods.toml")} {
+		file := filepath.Join("/snippets/my_team", rel)
+		name, err := dirs[0].remoteName(file)
+		require.NoError(t, err)
+		back, ok := localFile(dirs, name)
+		assert.True(t, ok)
+		assert.Equal(t, file, back)
+	}
+
+	// Names which would be split differently are refused
+	for _, rel := range []string{"docker__compose.toml", filepath.Join("k8s__prod", "pods.toml"), "_docker.toml", filepath.Join("k8s_", "pods.toml")} {
+		_, err := dirs[0].remoteName(filepath.Join("/snippets/my_team", rel))
+		assert.ErrorContains(t, err, "rename it to sync it", rel)
+	}
+
+	orig := config.Conf
+	defer func() { config.Conf = orig }()
+	config.Conf.General.SnippetDirs = []string{filepath.Join(t.TempDir(), "my__team")}
+	_, err := snippetDirs()
+	assert.ErrorContains(t, err, `"my__team" contains "__"`)
+}
+
+func TestMergeDryRun(t *testing.T) {
+	snippetFile := setupMergeTest(t, lsSnippet+psSnippet)
+	client := newMemoryClient()
+	require.NoError(t, merge(client, Options{}, strings.NewReader(""), &bytes.Buffer{}))
+
+	local := lsSnippet
+	require.NoError(t, os.WriteFile(snippetFile, []byte(local), 0644))
+	remote := strings.Replace(lsSnippet, `"ls"`, `"ls -a"`, 1) + psSnippet + dfSnippet
+	client.files[defaultRemoteFileName] = remote
+
+	var out bytes.Buffer
+	require.NoError(t, merge(client, Options{DryRun: true}, strings.NewReader(""), &out))
+	assert.Equal(t, `pet-snippet.toml
+  download ~ list
+  download + disk
+  upload   - processes
+`, out.String())
+
+	// Nothing was written
+	assert.Equal(t, local, readFile(t, snippetFile))
+	assert.Equal(t, remote, client.files[defaultRemoteFileName])
+	assert.Equal(t, 1, client.uploads)
+}
+
+func TestMergePreviewLeavesIDsUnsaved(t *testing.T) {
+	// A snippet written before snippets had IDs
+	local := "[[Snippets]]\n  Description = \"list\"\n  command = \"ls\"\n"
+	snippetFile := setupMergeTest(t, local)
+	client := newMemoryClient()
+
+	for _, opts := range []Options{{Status: true}, {DryRun: true}} {
+		require.NoError(t, merge(client, opts, strings.NewReader(""), &bytes.Buffer{}))
+	}
+
+	assert.Equal(t, local, readFile(t, snippetFile))
+	assert.NoFileExists(t, snippetFile+".bak")
+	journal, err := snippet.LoadJournal()
+	require.NoError(t, err)
+	assert.Empty(t, journal.Versions)
+	assert.Equal(t, 0, client.uploads)
+}
+
+func TestMergeStatus(t *testing.T) {
+	snippetFile := setupMergeTest(t, lsSnippet)
+	client := newMemoryClient()
+
+	var out bytes.Buffer
+	require.NoError(t, merge(client, Options{Status: true}, strings.NewReader(""), &out))
+	assert.Contains(t, out.String(), "Remote:    not created yet\n")
+	assert.Contains(t, out.String(), "Last sync: never\n")
+	assert.Contains(t, out.String(), "Direction: upload\n")
+	assert.Contains(t, out.String(), "  upload   + list\n")
+
+	require.NoError(t, merge(client, Options{}, strings.NewReader(""), &bytes.Buffer{}))
+	require.NoError(t, os.WriteFile(snippetFile, []byte(strings.Replace(lsSnippet, `"ls"`, `"ls -l"`, 1)), 0644))
+	client.files[defaultRemoteFileName] = strings.Replace(lsSnippet, `"ls"`, `"ls -a"`, 1)
+
+	out.Reset()
+	require.NoError(t, merge(client, Options{Status: true}, strings.NewReader(""), &out))
+	assert.NotContains(t, out.String(), "Last sync: never")
+	assert.Contains(t, out.String(), "Direction: resolve conflicts\n")
+	assert.Contains(t, out.String(), "  conflict ! list\n")
+}
+
+func TestMergePushAndPull(t *testing.T) {
+	snippetFile := setupMergeTest(t, lsSnippet)
+	client := newMemoryClient()
+	require.NoError(t, merge(client, Options{}, strings.NewReader(""), &bytes.Buffer{}))
+
+	require.NoError(t, os.WriteFile(snippetFile, []byte(strings.Replace(lsSnippet, `"ls"`, `"ls -l"`, 1)+psSnippet), 0644))
+	client.files[defaultRemoteFileName] = strings.Replace(lsSnippet, `"ls"`, `"ls -a"`, 1) + dfSnippet
+
+	// Conflicts are not asked about when one side wins
+	require.NoError(t, merge(client, Options{Push: true}, strings.NewReader(""), &bytes.Buffer{}))
+	assert.Equal(t, []string{"ls -l", "ps"}, snippetsOf(t, client.files[defaultRemoteFileName]))
+
+	client.files[defaultRemoteFileName] = dfSnippet
+	require.NoError(t, merge(client, Options{Pull: true}, strings.NewReader(""), &bytes.Buffer{}))
+	assert.Equal(t, []string{"df"}, snippetsOf(t, readFile(t, snippetFile)))
+}
+
+func TestRunRejectsPushWithPull(t *testing.T) {
+	assert.Error(t, Run(Options{Push: true, Pull: true}))
+}
+
+func TestMergeEncryptedSync(t *testing.T) {
+	snippetFile := setupMergeTest(t, lsSnippet)
+	t.Setenv("PET_PASSPHRASE", "correct horse")
+	client := newMemoryClient()
+
+	// Snippets uploaded before encryption was turned on are encrypted by the next sync
+	require.NoError(t, merge(client, Options{}, strings.NewReader(""), &bytes.Buffer{}))
+	assert.False(t, snippet.IsEncrypted([]byte(client.files[defaultRemoteFileName])))
+
+	config.Conf.Encryption.Sync = true
+	var out bytes.Buffer
+	require.NoError(t, merge(client, Options{}, strings.NewReader(""), &out))
+	assert.Equal(t, "Upload success\n", out.String())
+	remote := client.files[defaultRemoteFileName]
+	assert.True(t, snippet.IsEncrypted([]byte(remote)))
+	assert.NotContains(t, remote, "ls")
+
+	// Encrypted remote changes are merged and uploaded encrypted again
+	decrypted, err := snippet.Decrypt([]byte(remote))
+	require.NoError(t, err)
+	encrypted, err := snippet.Encrypt(append(decrypted, psSnippet...))
+	require.NoError(t, err)
+	client.files[defaultRemoteFileName] = string(encrypted)
+	require.NoError(t, os.WriteFile(snippetFile, []byte(lsSnippet+dfSnippet), 0644))
+
+	out.Reset()
+	require.NoError(t, merge(client, Options{}, strings.NewReader(""), &out))
+	assert.Equal(t, "Sync success\n", out.String())
+	assert.Equal(t, []string{"ls", "df", "ps"}, snippetsOf(t, readFile(t, snippetFile)))
+	assert.True(t, snippet.IsEncrypted([]byte(client.files[defaultRemoteFileName])))
+
+	// Nothing is uploaded while nothing changed
+	out.Reset()
+	require.NoError(t, merge(client, Options{}, strings.NewReader(""), &out))
+	assert.Equal(t, "Already up-to-date\n", out.String())
+
+	// A wrong passphrase stops the sync
+	t.Setenv("PET_PASSPHRASE", "wrong horse")
+	err = merge(client, Options{}, strings.NewReader(""), &out)
+	assert.ErrorContains(t, err, "wrong passphrase")
+}
+
+func TestMergeEncryptedFiles(t *testing.T) {
+	snippetFile := setupMergeTest(t, lsSnippet)
+	t.Setenv("PET_PASSPHRASE", "correct horse")
+	config.Conf.Encryption.Files = true
+	client := newMemoryClient()
+	client.files[defaultRemoteFileName] = lsSnippet + psSnippet
+
+	require.NoError(t, merge(client, Options{}, strings.NewReader(""), &bytes.Buffer{}))
+
+	// The downloaded snippets and the base of the next sync are encrypted on disk
+	local := readFile(t, snippetFile)
+	assert.True(t, snippet.IsEncrypted([]byte(local)))
+	dir, err := baseDir()
+	require.NoError(t, err)
+	assert.True(t, snippet.IsEncrypted([]byte(readFile(t, filepath.Join(dir, defaultRemoteFileName)))))
+
+	// while the remote snippets are not unless sync is encrypted too
+	assert.Equal(t, []string{"ls", "ps"}, snippetsOf(t, client.files[defaultRemoteFileName]))
+}
+
+func TestContentType(t *testing.T) {
+	assert.Equal(t, "application/toml", contentType("pet-snippet.toml"))
+	assert.Equal(t, "application/toml", contentType("snippet"))
+	assert.Equal(t, "application/yaml", contentType("team__docker.yaml"))
+	assert.Equal(t, "application/yaml", contentType("team__docker.YML"))
+	assert.Equal(t, "application/json", contentType("team__k8s.json"))
+}
@@ -0,0 +1,285 @@
+package sync
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/briandowns/spinner"
+	"github.com/knqyf263/pet/config"
+	"github.com/pkg/errors"
+)
+
+const (
+	webdavPasswordEnvVariable = "PET_WEBDAV_PASSWORD"
+	webdavTokenEnvVariable    = "PET_WEBDAV_TOKEN"
+)
+
+// WebDAVClient manages communication with a WebDAV server.
+// Each snippet file is a resource in the collection at Url.
+type WebDAVClient struct {
+	Client   *http.Client
+	Url      *url.URL
+	User     string
+	Password string
+	Token    string
+
+	// etags are the ETags of the resources as they were read, so that
+	// uploads fail rather than overwrite resources changed in the meantime
+	etags map[string]string
+}
+
+// NewWebDAVClient returns WebDAVClient
+func NewWebDAVClient() (Client, error) {
+	conf := config.Conf.WebDAV
+	if conf.Url == "" {
+		return nil, errors.New(`url is empty.
+Write the URL of the collection to url in [WebDAV] in config file (pet configure).`)
+	}
+
+	u, err := url.Parse(conf.Url)
+	if err != nil || u.Host == "" {
+		return nil, errors.Errorf("Invalid WebDAV url: %s", conf.Url)
+	}
+	if !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+	}
+
+	password := conf.Password
+	if password == "" {
+		password = os.Getenv(webdavPasswordEnvVariable)
+	}
+	token := conf.Token
+	if token == "" {
+		token = os.Getenv(webdavTokenEnvVariable)
+	}
+
+	return &WebDAVClient{
+		Client:   &http.Client{Timeout: 30 * time.Second},
+		Url:      u,
+		User:     conf.User,
+		Password: password,
+		Token:    token,
+		etags:    map[string]string{},
+	}, nil
+}
+
+// webdavMultistatus is the response of PROPFIND
+type webdavMultistatus struct {
+	Responses []webdavResponse `xml:"DAV: response"`
+}
+
+type webdavResponse struct {
+	Href     string           `xml:"DAV: href"`
+	Propstat []webdavPropstat `xml:"DAV: propstat"`
+}
+
+type webdavPropstat struct {
+	Status string     `xml:"DAV: status"`
+	Prop   webdavProp `xml:"DAV: prop"`
+}
+
+type webdavProp struct {
+	ETag         string `xml:"DAV: getetag"`
+	LastModified string `xml:"DAV: getlastmodified"`
+	ResourceType struct {
+		Collection *struct{} `xml:"DAV: collection"`
+	} `xml:"DAV: resourcetype"`
+}
+
+// webdavResource is a file listed in the collection
+type webdavResource struct {
+	name         string
+	lastModified time.Time
+}
+
+const webdavPropfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:">
+  <d:prop><d:resourcetype/><d:getetag/><d:getlastmodified/></d:prop>
+</d:propfind>`
+
+// GetSnippet returns the snippet files in the collection
+func (c *WebDAVClient) GetSnippet() (*Snippet, error) {
+	s := spinner.New(spinner.CharSets[14], 100*time.Millisecond)
+	s.Start()
+	s.Suffix = " Getting WebDAV files..."
+	defer s.Stop()
+
+	snippet := &Snippet{Files: map[string]string{}}
+	resources, err := c.list()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to list WebDAV files")
+	}
+
+	for _, r := range resources {
+		res, err := c.do(http.MethodGet, r.name, nil, nil)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to get WebDAV file %s", r.name)
+		}
+
+		snippet.Files[r.name] = string(res.body)
+		c.etags[r.name] = res.header.Get("ETag")
+
+		updatedAt := r.lastModified
+		if t, err := http.ParseTime(res.header.Get("Last-Modified")); err == nil {
+			updatedAt = t
+		}
+		if updatedAt.After(snippet.UpdatedAt) {
+			snippet.UpdatedAt = updatedAt
+		}
+	}
+	return snippet, nil
+}
+
+// UploadSnippet writes the snippet files, only if nobody else changed them since they were read
+func (c *WebDAVClient) UploadSnippet(files map[string]string) error {
+	s := spinner.New(spinner.CharSets[14], 100*time.Millisecond)
+	s.Start()
+	s.Suffix = " Uploading WebDAV files..."
+	defer s.Stop()
+
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		etag, exists := c.etags[name]
+
+		if files[name] == "" {
+			if !exists {
+				continue
+			}
+			header := http.Header{}
+			if etag != "" {
+				header.Set("If-Match", etag)
+			}
+			if _, err := c.do(http.MethodDelete, name, header, nil); err != nil {
+				return errors.Wrapf(err, "Failed to delete WebDAV file %s", name)
+			}
+			delete(c.etags, name)
+			continue
+		}
+
+		header := http.Header{}
+		if !exists {
+			header.Set("If-None-Match", "*")
+		} else if etag != "" {
+			header.Set("If-Match", etag)
+		}
+		header.Set("Content-Type", contentType(name))
+
+		res, err := c.do(http.MethodPut, name, header, []byte(files[name]))
+		if err != nil {
+			return errors.Wrapf(err, "Failed to put WebDAV file %s", name)
+		}
+		c.etags[name] = res.header.Get("ETag")
+	}
+	return nil
+}
+
+// list returns the files directly in the collection, creating the collection if it does not exist yet
+func (c *WebDAVClient) list() ([]webdavResource, error) {
+	header := http.Header{}
+	header.Set("Depth", "1")
+	header.Set("Content-Type", "application/xml")
+
+	res, err := c.do("PROPFIND", "", header, []byte(webdavPropfindBody))
+	if err == errWebDAVNotFound {
+		if _, err := c.do("MKCOL", "", nil, nil); err != nil {
+			return nil, errors.Wrap(err, "Failed to create the collection")
+		}
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var ms webdavMultistatus
+	if err := xml.Unmarshal(res.body, &ms); err != nil {
+		return nil, errors.Wrap(err, "Failed to parse the file list")
+	}
+
+	var resources []webdavResource
+	for _, r := range ms.Responses {
+		// href is either an absolute URL or an absolute path
+		href, err := url.Parse(r.Href)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Invalid href %s", r.Href)
+		}
+		p := href.Path
+		// The collection lists itself
+		if strings.TrimSuffix(p, "/") == strings.TrimSuffix(c.Url.Path, "/") {
+			continue
+		}
+
+		for _, ps := range r.Propstat {
+			if !strings.Contains(ps.Status, " 200 ") || ps.Prop.ResourceType.Collection != nil {
+				continue
+			}
+			resource := webdavResource{name: path.Base(p)}
+			if t, err := http.ParseTime(ps.Prop.LastModified); err == nil {
+				resource.lastModified = t
+			}
+			resources = append(resources, resource)
+		}
+	}
+	return resources, nil
+}
+
+// errWebDAVNotFound is returned when the resource does not exist
+var errWebDAVNotFound = errors.New("not found")
+
+// webdavResult is the part of a response pet needs
+type webdavResult struct {
+	header http.Header
+	body   []byte
+}
+
+// do sends an authenticated request for a file, or for the collection if name is empty
+func (c *WebDAVClient) do(method, name string, header http.Header, body []byte) (*webdavResult, error) {
+	u := *c.Url
+	u.Path += name
+	u.RawPath = ""
+
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	} else if c.User != "" {
+		req.SetBasicAuth(c.User, c.Password)
+	}
+
+	res, err := c.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	resBody, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case res.StatusCode == http.StatusPreconditionFailed:
+		return nil, errRemoteChanged
+	case res.StatusCode == http.StatusNotFound:
+		return nil, errWebDAVNotFound
+	case res.StatusCode >= 300:
+		return nil, errors.New(res.Status)
+	}
+	return &webdavResult{header: res.Header, body: resBody}, nil
+}
@@ -0,0 +1,222 @@
+package sync
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/knqyf263/pet/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var webdavModified = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// fakeWebDAV is a WebDAV server with a single collection kept in memory
+type fakeWebDAV struct {
+	t          *testing.T
+	collection string
+	auth       func(*http.Request) bool
+	mu         sync.Mutex
+	exists     bool
+	files      map[string]string
+}
+
+func (f *fakeWebDAV) etag(content string) string {
+	sum := md5.Sum([]byte(content))
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+func (f *fakeWebDAV) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !f.auth(r) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	name, ok := strings.CutPrefix(r.URL.Path, f.collection)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if name == "" {
+		switch r.Method {
+		case "MKCOL":
+			f.exists = true
+			w.WriteHeader(http.StatusCreated)
+		case "PROPFIND":
+			assert.Equal(f.t, "1", r.Header.Get("Depth"))
+			if !f.exists {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			f.propfind(w)
+		}
+		return
+	}
+
+	content, exists := f.files[name]
+	switch r.Method {
+	case http.MethodGet:
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("ETag", f.etag(content))
+		w.Header().Set("Last-Modified", webdavModified.Format(http.TimeFormat))
+		fmt.Fprint(w, content)
+	case http.MethodPut, http.MethodDelete:
+		if !f.exists {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		if match := r.Header.Get("If-Match"); match != "" && (!exists || match != f.etag(content)) ||
+			r.Header.Get("If-None-Match") == "*" && exists {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		if r.Method == http.MethodDelete {
+			delete(f.files, name)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		f.files[name] = string(body)
+		w.Header().Set("ETag", f.etag(string(body)))
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+func (f *fakeWebDAV) propfind(w http.ResponseWriter) {
+	var names []string
+	for name := range f.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusMultiStatus)
+	fmt.Fprint(w, `<?xml version="1.0"?><d:multistatus xmlns:d="DAV:">`)
+	fmt.Fprintf(w, `<d:response><d:href>%s</d:href><d:propstat><d:prop><d:resourcetype><d:collection/></d:resourcetype></d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>`, f.collection)
+	fmt.Fprintf(w, `<d:response><d:href>%snested/</d:href><d:propstat><d:prop><d:resourcetype><d:collection/></d:resourcetype></d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>`, f.collection)
+	for _, name := range names {
+		fmt.Fprintf(w, `<d:response><d:href>%s%s</d:href><d:propstat><d:prop><d:resourcetype/><d:getetag>%s</d:getetag><d:getlastmodified>%s</d:getlastmodified></d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>`,
+			f.collection, strings.ReplaceAll(name, " ", "%20"), f.etag(f.files[name]), webdavModified.Format(http.TimeFormat))
+	}
+	fmt.Fprint(w, `</d:multistatus>`)
+}
+
+func newFakeWebDAVClient(t *testing.T, conf config.WebDAVConfig, auth func(*http.Request) bool) (*WebDAVClient, *fakeWebDAV) {
+	fake := &fakeWebDAV{t: t, collection: "/dav/pet/", auth: auth, exists: true, files: map[string]string{}}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	orig := config.Conf
+	t.Cleanup(func() { config.Conf = orig })
+	conf.Url = server.URL + "/dav/pet"
+	config.Conf.WebDAV = conf
+
+	client, err := NewWebDAVClient()
+	require.NoError(t, err)
+	return client.(*WebDAVClient), fake
+}
+
+func basicAuth(r *http.Request) bool {
+	user, password, ok := r.BasicAuth()
+	return ok && user == "me" && password == "secret"
+}
+
+func TestWebDAVClient(t *testing.T) {
+	client, fake := newFakeWebDAVClient(t, config.WebDAVConfig{User: "me", Password: "secret"}, basicAuth)
+	fake.files["team__docker.toml"] = "docker"
+	fake.files["my notes.toml"] = "notes"
+
+	snippet, err := client.GetSnippet()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"team__docker.toml": "docker", "my notes.toml": "notes"}, snippet.Files)
+	assert.True(t, webdavModified.Equal(snippet.UpdatedAt))
+
+	require.NoError(t, client.UploadSnippet(map[string]string{
+		"pet-snippet.toml":  "main",
+		"team__docker.toml": "",
+	}))
+	assert.Equal(t, map[string]string{"pet-snippet.toml": "main", "my notes.toml": "notes"}, fake.files)
+
+	// Uploads after an upload use the new ETags
+	require.NoError(t, client.UploadSnippet(map[string]string{"pet-snippet.toml": "main 2"}))
+	assert.Equal(t, "main 2", fake.files["pet-snippet.toml"])
+}
+
+func TestWebDAVClientCreatesCollection(t *testing.T) {
+	client, fake := newFakeWebDAVClient(t, config.WebDAVConfig{User: "me", Password: "secret"}, basicAuth)
+	fake.exists = false
+
+	snippet, err := client.GetSnippet()
+	require.NoError(t, err)
+	assert.Empty(t, snippet.Files)
+	assert.True(t, snippet.UpdatedAt.IsZero())
+	assert.True(t, fake.exists)
+
+	require.NoError(t, client.UploadSnippet(map[string]string{"pet-snippet.toml": "main"}))
+	assert.Equal(t, map[string]string{"pet-snippet.toml": "main"}, fake.files)
+}
+
+func TestWebDAVClientBearerToken(t *testing.T) {
+	t.Setenv(webdavTokenEnvVariable, "token")
+	client, fake := newFakeWebDAVClient(t, config.WebDAVConfig{}, func(r *http.Request) bool {
+		return r.Header.Get("Authorization") == "Bearer token"
+	})
+	fake.files["pet-snippet.toml"] = "main"
+
+	snippet, err := client.GetSnippet()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"pet-snippet.toml": "main"}, snippet.Files)
+
+	client.Token = "wrong"
+	_, err = client.GetSnippet()
+	assert.ErrorContains(t, err, "401 Unauthorized")
+}
+
+func TestWebDAVClientDoesNotOverwriteConcurrentChanges(t *testing.T) {
+	client, fake := newFakeWebDAVClient(t, config.WebDAVConfig{User: "me", Password: "secret"}, basicAuth)
+	fake.files["pet-snippet.toml"] = "main"
+
+	_, err := client.GetSnippet()
+	require.NoError(t, err)
+
+	// Another machine uploads in the meantime
+	fake.files["pet-snippet.toml"] = "changed elsewhere"
+	fake.files["team__new.toml"] = "created elsewhere"
+
+	err = client.UploadSnippet(map[string]string{"pet-snippet.toml": "main 2"})
+	assert.ErrorContains(t, err, "changed since they were read")
+	err = client.UploadSnippet(map[string]string{"team__new.toml": "new"})
+	assert.ErrorContains(t, err, "changed since they were read")
+
+	assert.Equal(t, "changed elsewhere", fake.files["pet-snippet.toml"])
+	assert.Equal(t, "created elsewhere", fake.files["team__new.toml"])
+}
+
+func TestNewWebDAVClient(t *testing.T) {
+	orig := config.Conf
+	defer func() { config.Conf = orig }()
+
+	config.Conf.WebDAV = config.WebDAVConfig{}
+	_, err := NewWebDAVClient()
+	assert.ErrorContains(t, err, "url is empty")
+
+	config.Conf.WebDAV = config.WebDAVConfig{Url: "not a url"}
+	_, err = NewWebDAVClient()
+	assert.ErrorContains(t, err, "Invalid WebDAV url")
+}